@@ -17,24 +17,76 @@ limitations under the License.
 package v1
 
 import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 type ApplicationType string
 
-// +kubebuilder:validation:Enum=Deploying;Errored;Deployed;Rejected
+// +kubebuilder:validation:Enum=Deploying;Errored;Deployed;Rejected;RolledBack;Suspended;Previewed
 type ApplicationDeploymentState string
 
 const (
-	Deploying ApplicationDeploymentState = "Deploying"
-	Deployed  ApplicationDeploymentState = "Deployed"
-	Rejected  ApplicationDeploymentState = "Rejected"
-	Errored   ApplicationDeploymentState = "Errored"
+	Deploying  ApplicationDeploymentState = "Deploying"
+	Deployed   ApplicationDeploymentState = "Deployed"
+	Rejected   ApplicationDeploymentState = "Rejected"
+	Errored    ApplicationDeploymentState = "Errored"
+	RolledBack ApplicationDeploymentState = "RolledBack"
+	Suspended  ApplicationDeploymentState = "Suspended"
+	Previewed  ApplicationDeploymentState = "Previewed"
 )
 const (
 	Helm ApplicationType = "Helm"
 )
 
+// +kubebuilder:validation:Enum=Correct;Report
+type DriftPolicy string
+
+const (
+	// Re-apply the desired state when federated objects were changed or deleted
+	DriftCorrect DriftPolicy = "Correct"
+	// Only report changed or deleted federated objects in the status
+	DriftReport DriftPolicy = "Report"
+)
+
+// +kubebuilder:validation:Enum=Never;IfUnowned;Always
+type AdoptionPolicy string
+
+const (
+	// Never modify federated objects the application did not create
+	AdoptNever AdoptionPolicy = "Never"
+	// Adopt federated objects not owned by another application
+	AdoptIfUnowned AdoptionPolicy = "IfUnowned"
+	// Adopt federated objects not owned by another existing application , including objects left
+	// behind by deleted applications
+	AdoptAlways AdoptionPolicy = "Always"
+)
+
+// PreviewAnnotation set to "true" has the same effect as spec.dryRun
+const PreviewAnnotation = "federation.kubefed.fulliautomatix.site/preview"
+
+const (
+	// ApplicationNameLabel is set on every federated object generated for an application
+	ApplicationNameLabel = "federation.kubefed.fulliautomatix.site/application"
+	// ApplicationNamespaceLabel is the namespace of the application owning a federated object ,
+	// empty for cluster applications
+	ApplicationNamespaceLabel = "federation.kubefed.fulliautomatix.site/application-namespace"
+)
+
+// +kubebuilder:validation:Enum=Healthy;Progressing;Degraded;Unknown
+type HealthState string
+
+const (
+	Healthy     HealthState = "Healthy"
+	Progressing HealthState = "Progressing"
+	Degraded    HealthState = "Degraded"
+	Unknown     HealthState = "Unknown"
+)
+
 type ApplicationTemplateSpec struct {
 	Chart HelmChartSpec `json:"chart"`
 }
@@ -48,13 +100,50 @@ type HelmChartSpec struct {
 	// +kubebuilder:validation:Optional
 	Namespace string `json:"namespace"`
 
-	// Repository to fetch the helm chart from
-	// +kubebuilder:validation:required
-	Repo string `json:"repoUrl"`
+	// Repository to fetch the helm chart from , required unless repositoryRef is set
+	// +optional
+	Repo string `json:"repoUrl,omitempty"`
+
+	// Name of a HelmRepository in the namespace of the application to fetch the helm chart from
+	// +optional
+	RepositoryRef string `json:"repositoryRef,omitempty"`
 
 	// Installing a specific version
 	// +kubebuilder:validation:Optional
 	Version string `json:"version,omitempty"`
+
+	// Values overriding the defaults of the chart
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Values *runtime.RawExtension `json:"values,omitempty"`
+}
+
+// ChartValues decodes the values of the chart
+func (chart HelmChartSpec) ChartValues() (map[string]interface{}, error) {
+	if chart.Values == nil || len(chart.Values.Raw) == 0 {
+		return nil, nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(chart.Values.Raw, &values); err != nil {
+		return nil, fmt.Errorf("Invalid chart values: %v", err)
+	}
+	return values, nil
+}
+
+// RollbackPolicy defines when a failed deployment is rolled back to the last good revision
+type RollbackPolicy struct {
+	// Automatically re-apply the last successful revision on failure
+	Enabled bool `json:"enabled"`
+
+	// Number of consecutive failed deployments before rolling back , defaults to 3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+
+	// How long the workloads may stay unhealthy after a deployment before rolling back ,
+	// unhealthy workloads never trigger a rollback when unset
+	// +optional
+	HealthTimeout *metav1.Duration `json:"healthTimeout,omitempty"`
 }
 
 // ApplicationSpec defines the desired state of Application
@@ -64,6 +153,116 @@ type ApplicationSpec struct {
 	Type ApplicationType `json:"type"`
 	// +kubebuilder:validation:Required
 	Template ApplicationTemplateSpec `json:"template"`
+
+	// Helm release name the chart is rendered with , defaults to the application name and cannot be changed
+	// +optional
+	ReleaseName string `json:"releaseName,omitempty"`
+
+	// ServiceAccount in the namespace of the application impersonated to apply the federated objects ,
+	// the controller's own account is used when empty
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Kubernetes version the chart is rendered against , discovered from the member clusters when
+	// neither kubeVersion nor apiVersions are set
+	// +optional
+	KubeVersion string `json:"kubeVersion,omitempty"`
+
+	// API versions available to the chart in addition to the helm defaults , e.g. networking.k8s.io/v1/Ingress
+	// +optional
+	APIVersions []string `json:"apiVersions,omitempty"`
+
+	// Stop rendering and applying the application until it is resumed , deletion is still handled
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Only preview the changes to the federated objects in the status without applying them
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Automatic rollback of failed deployments
+	// +optional
+	Rollback *RollbackPolicy `json:"rollback,omitempty"`
+
+	// Number of revisions to keep , defaults to 10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// Re-deploy the exact rendered output of a previous revision instead of rendering the chart
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RollbackTo *int `json:"rollbackTo,omitempty"`
+
+	// Roll out new revisions to the member clusters in steps instead of all at once
+	// +optional
+	RolloutStrategy *RolloutStrategy `json:"rolloutStrategy,omitempty"`
+
+	// What to do when generated federated objects are changed or deleted , defaults to Correct
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
+	// How the federated objects are server side applied
+	// +optional
+	ApplyPolicy *ApplyPolicy `json:"applyPolicy,omitempty"`
+
+	// Whether existing federated objects not created by the application are taken over , defaults to Never
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+}
+
+// ApplyPolicy defines how field ownership is handled when applying federated objects
+type ApplyPolicy struct {
+	// Take ownership of fields managed by other field managers instead of failing with a conflict
+	// +optional
+	Force bool `json:"force,omitempty"`
+
+	// Fields left to other field managers , e.g. replicas owned by an autoscaler
+	// +optional
+	ReleaseFields []ReleasedField `json:"releaseFields,omitempty"`
+}
+
+// ReleasedField is a field of the federated objects that is not applied by the controller
+type ReleasedField struct {
+	// Federated kind the field is released for , all kinds when empty
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Path of the field in the federated object , e.g. .spec.template.spec.replicas
+	Path string `json:"path"`
+}
+
+// ApproveRolloutStepAnnotation approves a paused rollout step , its value is the number of the step
+const ApproveRolloutStepAnnotation = "federation.kubefed.fulliautomatix.site/approve-rollout-step"
+
+// RolloutStrategy defines the batches of member clusters a new revision is rolled out to
+type RolloutStrategy struct {
+	// Ordered steps , the last step always completes the rollout to all member clusters
+	// +kubebuilder:validation:MinItems=1
+	Steps []RolloutStep `json:"steps"`
+
+	// Minimum time a step runs before the rollout continues , even when the updated clusters are
+	// healthy earlier , one minute when unset
+	// +optional
+	StepInterval *metav1.Duration `json:"stepInterval,omitempty"`
+}
+
+// RolloutStep adds member clusters to the rollout
+type RolloutStep struct {
+	// Member clusters updated in this step , e.g. a canary cluster
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+
+	// Percentage of all member clusters updated once this step is done ,
+	// all remaining clusters when neither clusters nor percentage are set
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	Percentage int32 `json:"percentage,omitempty"`
+
+	// Wait for the step to be approved through the approve-rollout-step annotation before continuing
+	// +optional
+	Pause bool `json:"pause,omitempty"`
 }
 
 // ApplicationStatus defines the observed state of Application
@@ -71,9 +270,278 @@ type ApplicationStatus struct {
 	State ApplicationDeploymentState `json:"state,omitempty"`
 
 	DeployedTimestamp *metav1.Time `json:"deployedAt,omitempty"`
+
+	// Aggregated health of the propagated workloads across all member clusters
+	// +optional
+	Health HealthState `json:"health,omitempty"`
+
+	// Health of the propagated workloads per member cluster
+	// +optional
+	Clusters []ClusterStatus `json:"clusters,omitempty"`
+
+	// Revision currently applied to the kubefed control plane
+	// +optional
+	CurrentRevision int `json:"currentRevision,omitempty"`
+
+	// Number of consecutive failed deployments
+	// +optional
+	FailureCount int32 `json:"failureCount,omitempty"`
+
+	// Last automatic rollback , set while the failed spec is unchanged
+	// +optional
+	Rollback *RollbackStatus `json:"rollback,omitempty"`
+
+	// Revisions kept for the application , oldest first
+	// +optional
+	History []RevisionHistory `json:"history,omitempty"`
+
+	// Progress of rolling out the current revision
+	// +optional
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+
+	// Federated objects whose live state differs from the current revision
+	// +optional
+	Drift []ResourceDrift `json:"drift,omitempty"`
+
+	// Fields of the federated objects owned by other field managers that prevented the last apply
+	// +optional
+	Conflicts []FieldConflict `json:"conflicts,omitempty"`
+
+	// Existing federated objects the application is not allowed to adopt
+	// +optional
+	Clashes []OwnershipClash `json:"clashes,omitempty"`
+
+	// What the application deploys that the application policies of its namespace do not allow
+	// +optional
+	PolicyViolations []string `json:"policyViolations,omitempty"`
+
+	// Changes the spec would make to the federated objects , set in dry run mode
+	// +optional
+	Preview *PreviewStatus `json:"preview,omitempty"`
+
+	// Latest observations of the application
+	// +optional
+	Conditions []ApplicationCondition `json:"conditions,omitempty"`
+}
+
+type ApplicationConditionType string
+
+const (
+	// ValuesValid is true when the chart values match the values schema of the chart
+	ValuesValid ApplicationConditionType = "ValuesValid"
+	// Propagated is true once kubefed propagated the federated objects of the current revision to the member clusters
+	Propagated ApplicationConditionType = "Propagated"
+	// PolicyAllowed is false when the application policies of the namespace do not allow the application ,
+	// naming what they do not allow
+	PolicyAllowed ApplicationConditionType = "PolicyAllowed"
+	// ResourcesOwned is false when existing federated objects the application may not adopt block its
+	// deployment , listed in status.clashes
+	ResourcesOwned ApplicationConditionType = "ResourcesOwned"
+)
+
+// ApplicationCondition is an observation of the application
+type ApplicationCondition struct {
+	Type ApplicationConditionType `json:"type"`
+
+	// One of True , False or Unknown
+	Status corev1.ConditionStatus `json:"status"`
+
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Last time the status of the condition changed
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// SetCondition adds or updates the condition , keeping the transition time while the status is unchanged
+func (status *ApplicationStatus) SetCondition(condition ApplicationCondition) {
+	for i := range status.Conditions {
+		existing := &status.Conditions[i]
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status {
+			condition.LastTransitionTime = existing.LastTransitionTime
+		} else {
+			condition.LastTransitionTime = metav1.Now()
+		}
+		*existing = condition
+		return
+	}
+	condition.LastTransitionTime = metav1.Now()
+	status.Conditions = append(status.Conditions, condition)
+}
+
+// GetCondition returns the condition of the given type , or nil when not observed yet
+func (status *ApplicationStatus) GetCondition(conditionType ApplicationConditionType) *ApplicationCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// PreviewStatus is the difference between the live federated objects and the rendered spec
+type PreviewStatus struct {
+	GeneratedAt metav1.Time `json:"generatedAt"`
+
+	// Federated objects that would be created
+	// +optional
+	Added []ResourceReference `json:"added,omitempty"`
+
+	// Federated objects that would be changed
+	// +optional
+	Changed []ResourceChange `json:"changed,omitempty"`
+
+	// Federated objects of the current revision no longer rendered
+	// +optional
+	Removed []ResourceReference `json:"removed,omitempty"`
+}
+
+// ResourceChange lists the fields of a federated object that would change
+type ResourceChange struct {
+	ResourceReference `json:",inline"`
+
+	// Paths of the changed fields
+	Fields []string `json:"fields"`
+}
+
+// OwnershipClash is an existing federated object owned by something else than the application
+type OwnershipClash struct {
+	ResourceReference `json:",inline"`
+
+	// Namespace and name of the application owning the object , empty when not created by an application
+	// +optional
+	Owner string `json:"owner,omitempty"`
+}
+
+// FieldConflict is a field of a federated object owned by another field manager
+type FieldConflict struct {
+	ResourceReference `json:",inline"`
+
+	// Path of the conflicting field
+	Field string `json:"field"`
+
+	// Field manager owning the field
+	// +optional
+	Manager string `json:"manager,omitempty"`
+}
+
+// ResourceDrift describes a federated object that was changed or deleted outside of the controller
+type ResourceDrift struct {
+	ResourceReference `json:",inline"`
+
+	// The object was deleted
+	// +optional
+	Deleted bool `json:"deleted,omitempty"`
+
+	// Paths of the changed fields
+	// +optional
+	Fields []string `json:"fields,omitempty"`
+
+	// The desired state was re-applied
+	// +optional
+	Corrected bool `json:"corrected,omitempty"`
+}
+
+// RolloutStatus defines the progress of a rollout across member clusters
+type RolloutStatus struct {
+	// Revision being rolled out
+	Revision int `json:"revision"`
+
+	// Revision the clusters not yet updated are kept at
+	StableRevision int `json:"stableRevision"`
+
+	// Current step , starting at 1
+	Step int `json:"step"`
+
+	// Member clusters running the new revision
+	// +optional
+	UpdatedClusters []string `json:"updatedClusters,omitempty"`
+
+	// When the current step started updating its clusters
+	// +optional
+	StepStartedAt *metav1.Time `json:"stepStartedAt,omitempty"`
+
+	// Waiting for the current step to be approved
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// The new revision runs on all member clusters
+	// +optional
+	Complete bool `json:"complete,omitempty"`
+
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// RevisionHistory describes a recorded revision of the application
+type RevisionHistory struct {
+	Revision int `json:"revision"`
+
+	// Version of the chart the revision was rendered from
+	// +optional
+	ChartVersion string `json:"chartVersion,omitempty"`
+
+	// Digest of the chart values the revision was rendered with
+	// +optional
+	ValuesHash string `json:"valuesHash,omitempty"`
+
+	// One of Pending , Succeeded or Failed
+	Status string `json:"status"`
+
+	DeployedAt metav1.Time `json:"deployedAt"`
+
+	// Federated objects created by the revision
+	// +optional
+	Resources []ResourceReference `json:"resources,omitempty"`
+}
+
+// ResourceReference identifies a federated object
+type ResourceReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// RollbackStatus records an automatic rollback
+type RollbackStatus struct {
+	// Revision that was re-applied
+	Revision int `json:"revision"`
+
+	// Revision that failed
+	FailedRevision int `json:"failedRevision,omitempty"`
+
+	// Hash of the spec inputs that failed , the rollback is kept in place until they change
+	FailedInputsHash string `json:"failedInputsHash"`
+
+	// Why the rollback happened
+	Reason string `json:"reason"`
+
+	RolledBackAt metav1.Time `json:"rolledBackAt"`
+}
+
+// ClusterStatus defines the observed state of the application in a member cluster
+type ClusterStatus struct {
+	// Name of the member cluster
+	Name string `json:"name"`
+
+	Health HealthState `json:"health"`
+
+	// Reason for the cluster not being healthy
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
 
 // Application is the Schema for the applications API
 type Application struct {
@@ -84,6 +552,14 @@ type Application struct {
 	Status ApplicationStatus `json:"status,omitempty"`
 }
 
+// ReleaseName is the helm release name the chart is rendered with
+func (r *Application) ReleaseName() string {
+	if r.Spec.ReleaseName != "" {
+		return r.Spec.ReleaseName
+	}
+	return r.Name
+}
+
 // +kubebuilder:object:root=true
 
 // ApplicationList contains a list of Application
@@ -17,9 +17,18 @@ limitations under the License.
 package v1
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -27,7 +36,41 @@ import (
 // log is for logging in this package.
 var applicationlog = logf.Log.WithName("application-resource")
 
-func (r *Application) SetupWebhookWithManager(mgr ctrl.Manager) error {
+// applicationReader lists the existing applications to detect release collisions , unset outside of the manager
+var applicationReader client.Reader
+
+// apiReader reads the repository policy ConfigMap and the credentials of referenced HelmRepositories
+// without caching every ConfigMap and Secret of the cluster
+var apiReader client.Reader
+
+// ChartResolver fetches a chart with the basic auth credentials of the repository and returns the version
+// it resolves to , cancelling the download once the context is done
+// +kubebuilder:object:generate=false
+type ChartResolver func(ctx context.Context, chartName string, chartRepo string, version string, username string, password string) (string, error)
+
+// WebhookOptions configure the validation of applications
+// +kubebuilder:object:generate=false
+type WebhookOptions struct {
+	// Chart repositories applications may use
+	Repositories RepositoryPolicy
+	// ConfigMap holding additional repository patterns in its allowed and denied keys
+	RepositoryPolicyConfigMap types.NamespacedName
+	// Resolves the chart of every application when set
+	ResolveChart ChartResolver
+	// Time budget of resolving a chart
+	ResolveTimeout time.Duration
+	// Renders and federates the chart of every application when set , stopping once the context is done
+	ValidateRender func(ctx context.Context, application *Application) error
+	// Time budget of rendering a chart
+	RenderTimeout time.Duration
+}
+
+var webhookOptions WebhookOptions
+
+func (r *Application) SetupWebhookWithManager(mgr ctrl.Manager, options WebhookOptions) error {
+	applicationReader = mgr.GetClient()
+	apiReader = mgr.GetAPIReader()
+	webhookOptions = options
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -44,9 +87,13 @@ func (r *Application) Default() {
 	if r.Spec.Type == "" {
 		r.Spec.Type = Helm
 	}
+	if r.Spec.ReleaseName == "" {
+		r.Spec.ReleaseName = r.Name
+	}
 }
 
 // TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
 // +kubebuilder:webhook:verbs=create;update,path=/validate-federation-kubefed-fulliautomatix-site-v1-application,mutating=false,failurePolicy=fail,groups=federation.kubefed.fulliautomatix.site,resources=applications,versions=v1,name=vapplication.kb.io
 
 var _ webhook.Validator = &Application{}
@@ -54,7 +101,13 @@ var _ webhook.Validator = &Application{}
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *Application) ValidateCreate() error {
 	applicationlog.Info("validate create", "name", r.Name)
-	return r.validateApplication()
+	if err := r.validateApplication(); err != nil {
+		return err
+	}
+	if err := r.validatePolicies(); err != nil {
+		return err
+	}
+	return r.validateReleaseCollision()
 }
 
 func (application *Application) validateApplication() error {
@@ -66,19 +119,195 @@ func (application *Application) validateApplication() error {
 	if chartName == "" {
 		return fmt.Errorf("Invalid/empty chart name")
 	}
-	repourl := application.Spec.Template.Chart.Repo
+	chart := application.Spec.Template.Chart
+	if chart.Repo != "" && chart.RepositoryRef != "" {
+		return fmt.Errorf("Set either repoUrl or repositoryRef , not both")
+	}
+	if chart.Repo == "" && chart.RepositoryRef == "" {
+		return fmt.Errorf("Repo url is a required field , unless a HelmRepository is referenced with repositoryRef")
+	}
+	repository, err := ResolveChartRepository(apiReader, application.Namespace, chart)
+	if err != nil {
+		return err
+	}
+	repositories, err := repositoryPolicy()
+	if err != nil {
+		return err
+	}
+	if err := repositories.Check(repository.URL); err != nil {
+		return err
+	}
+	if err := application.resolveChart(repository); err != nil {
+		return err
+	}
+	return application.validateRender()
+}
+
+// repositoryPolicy combines the repository patterns of the options and of the policy ConfigMap
+func repositoryPolicy() (RepositoryPolicy, error) {
+	return LoadRepositoryPolicy(apiReader, webhookOptions.Repositories, webhookOptions.RepositoryPolicyConfigMap)
+}
 
-	if repourl == "" {
-		return fmt.Errorf("Repo url is a required field ")
+// LoadRepositoryPolicy adds the repository patterns of the policy ConfigMap , in its allowed and denied
+// keys , to the policy
+func LoadRepositoryPolicy(reader client.Reader, policy RepositoryPolicy, name types.NamespacedName) (RepositoryPolicy, error) {
+	if reader == nil || name.Name == "" {
+		return policy, nil
+	}
+	var configMap corev1.ConfigMap
+	err := reader.Get(context.TODO(), name, &configMap)
+	if apierrors.IsNotFound(err) {
+		return policy, nil
+	}
+	if err != nil {
+		return policy, fmt.Errorf("Unable to read the repository policy %s: %v", name, err)
+	}
+	return policy.Merge(RepositoryPolicy{
+		Allowed: ParseRepositoryPatterns(configMap.Data["allowed"]),
+		Denied:  ParseRepositoryPatterns(configMap.Data["denied"]),
+	}), nil
+}
+
+// resolveChart makes sure the chart version exists in the repository , within the time budget
+func (application *Application) resolveChart(repository ChartRepository) error {
+	if webhookOptions.ResolveChart == nil {
+		return nil
+	}
+	chart := application.Spec.Template.Chart
+	return withTimeout(webhookOptions.ResolveTimeout, fmt.Sprintf("resolving chart %s from repository %s", chart.Name, repository.URL), func(ctx context.Context) error {
+		version, err := webhookOptions.ResolveChart(ctx, chart.Name, repository.URL, chart.Version, repository.Username, repository.Password)
+		if err != nil {
+			return fmt.Errorf("Chart %s version %q not found in repository %s: %v", chart.Name, chart.Version, repository.URL, err)
+		}
+		applicationlog.Info("resolved chart", "name", application.Name, "chart", chart.Name, "version", version)
+		return nil
+	})
+}
+
+// validateRender makes sure the chart renders to federated objects , within the time budget
+func (application *Application) validateRender() error {
+	if webhookOptions.ValidateRender == nil {
+		return nil
+	}
+	return withTimeout(webhookOptions.RenderTimeout, fmt.Sprintf("rendering chart %s", application.Spec.Template.Chart.Name), func(ctx context.Context) error {
+		return webhookOptions.ValidateRender(ctx, application)
+	})
+}
+
+// withTimeout runs the validation , failing once the time budget is spent and cancelling the context
+// of the validation so it stops
+func withTimeout(timeout time.Duration, action string, validate func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		result <- validate(ctx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("Timed out %s after %s", action, timeout)
 	}
-	// TODO : maybe add validation to check if its a valid chart by downloading
-	return nil
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *Application) ValidateUpdate(old runtime.Object) error {
 	applicationlog.Info("validate update", "name", r.Name)
-	return r.validateApplication()
+	if oldApplication, ok := old.(*Application); ok && specUnchanged(oldApplication, r) {
+		return nil
+	}
+	if err := r.validateApplication(); err != nil {
+		return err
+	}
+	if oldApplication, ok := old.(*Application); ok && oldApplication.ReleaseName() != r.ReleaseName() {
+		return fmt.Errorf("Release name %s cannot be changed to %s", oldApplication.ReleaseName(), r.ReleaseName())
+	}
+	if err := r.validatePolicies(); err != nil {
+		return err
+	}
+	if oldApplication, ok := old.(*Application); ok && !releaseMoved(oldApplication, r) {
+		return nil
+	}
+	return r.validateReleaseCollision()
+}
+
+// releaseMoved tells if the update deploys the release into another namespace , other spec changes
+// cannot cause a release collision and an existing collision is reported by the controller
+func releaseMoved(old *Application, application *Application) bool {
+	return old.ReleaseName() != application.ReleaseName() || old.Spec.Template.Chart.Namespace != application.Spec.Template.Chart.Namespace
+}
+
+// specUnchanged tells if the update only touches the metadata of the application , e.g. the finalizers
+// removed while it is deleted , the controller must be able to finalize applications it rejected
+func specUnchanged(old *Application, application *Application) bool {
+	if !application.ObjectMeta.DeletionTimestamp.IsZero() {
+		return true
+	}
+	return apiequality.Semantic.DeepEqual(old.Spec, application.Spec)
+}
+
+// validatePolicies rejects applications not allowed by the application policies of their namespace ,
+// the generated federated kinds are only checked by the controller once the chart is rendered
+func (application *Application) validatePolicies() error {
+	if applicationReader == nil {
+		return nil
+	}
+	repository, err := ResolveChartRepository(apiReader, application.Namespace, application.Spec.Template.Chart)
+	if err != nil {
+		return err
+	}
+	// referenced repositories are allowed by the policies their url is allowed by
+	resolved := application.WithChartRepository(repository)
+	var policies ApplicationPolicyList
+	if err := applicationReader.List(context.TODO(), &policies); err != nil {
+		return fmt.Errorf("Unable to list application policies: %v", err)
+	}
+	var violations []string
+	for _, policy := range policies.Items {
+		if policy.AppliesTo(application.Namespace) {
+			violations = append(violations, policy.Violations(resolved)...)
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("Application violates its application policies: %s", strings.Join(violations, " , "))
+	}
+	return nil
+}
+
+// validateReleaseCollision rejects applications rendering the same release into the same namespace
+// as an existing application or cluster application , they would overwrite each other's federated objects
+func (application *Application) validateReleaseCollision() error {
+	if applicationReader == nil {
+		return nil
+	}
+	var applications ApplicationList
+	if err := applicationReader.List(context.TODO(), &applications); err != nil {
+		return fmt.Errorf("Unable to list applications: %v", err)
+	}
+	var clusterApplications ClusterApplicationList
+	if err := applicationReader.List(context.TODO(), &clusterApplications); err != nil {
+		return fmt.Errorf("Unable to list cluster applications: %v", err)
+	}
+	for _, clusterApplication := range clusterApplications.Items {
+		applications.Items = append(applications.Items, *clusterApplication.Application())
+	}
+	for _, existing := range applications.Items {
+		if existing.Namespace == application.Namespace && existing.Name == application.Name {
+			continue
+		}
+		if !existing.ObjectMeta.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if existing.ReleaseName() == application.ReleaseName() && existing.Spec.Template.Chart.Namespace == application.Spec.Template.Chart.Namespace {
+			owner := fmt.Sprintf("application %s/%s", existing.Namespace, existing.Name)
+			if existing.Namespace == "" {
+				owner = fmt.Sprintf("cluster application %s", existing.Name)
+			}
+			return fmt.Errorf("Release %s in namespace %s is already deployed by %s", application.ReleaseName(), application.Spec.Template.Chart.Namespace, owner)
+		}
+	}
+	return nil
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
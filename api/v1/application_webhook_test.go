@@ -0,0 +1,249 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestApplication is a valid application of the team-a namespace
+func newTestApplication(name string) *Application {
+	return &Application{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"},
+		Spec: ApplicationSpec{
+			Type: Helm,
+			Template: ApplicationTemplateSpec{
+				Chart: HelmChartSpec{
+					Name:      "web",
+					Namespace: "web",
+					Repo:      "https://charts.example.com/",
+				},
+			},
+		},
+	}
+}
+
+// useObjects makes the webhooks read the objects instead of a cluster
+func useObjects(objects ...runtime.Object) {
+	scheme := runtime.NewScheme()
+	Expect(AddToScheme(scheme)).To(Succeed())
+	Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	reader := fake.NewFakeClientWithScheme(scheme, objects...)
+	applicationReader = reader
+	apiReader = reader
+}
+
+var _ = Describe("Application webhook", func() {
+	AfterEach(func() {
+		applicationReader = nil
+		apiReader = nil
+		webhookOptions = WebhookOptions{}
+	})
+
+	Context("With an application violating its policies", func() {
+		var application *Application
+
+		BeforeEach(func() {
+			useObjects(&ApplicationPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+				Spec: ApplicationPolicySpec{
+					SourceNamespaces: []string{"team-a"},
+					TargetNamespaces: []string{"team-a-*"},
+				},
+			})
+			application = newTestApplication("web")
+		})
+
+		It("Should refuse to create it", func() {
+			Expect(application.ValidateCreate()).To(MatchError(ContainSubstring("target namespace web is not allowed by policy team-a")))
+		})
+
+		It("Should let the controller record the rejection and delete it", func() {
+			rejected := application.DeepCopy()
+			rejected.ObjectMeta.Finalizers = []string{"applicatio.finalizers.federation.kubefed.fulliautomatix.site"}
+			rejected.Status.State = Rejected
+			Expect(rejected.ValidateUpdate(application)).To(Succeed())
+
+			deleted := rejected.DeepCopy()
+			now := metav1.Now()
+			deleted.ObjectMeta.DeletionTimestamp = &now
+			deleted.ObjectMeta.Finalizers = nil
+			Expect(deleted.ValidateUpdate(rejected)).To(Succeed())
+		})
+
+		It("Should refuse spec changes still violating the policies", func() {
+			changed := application.DeepCopy()
+			changed.Spec.Template.Chart.Version = "1.2.3"
+			Expect(changed.ValidateUpdate(application)).To(MatchError(ContainSubstring("violates its application policies")))
+		})
+
+		It("Should accept spec changes complying with the policies", func() {
+			changed := application.DeepCopy()
+			changed.Spec.Template.Chart.Namespace = "team-a-web"
+			Expect(changed.ValidateUpdate(application)).To(Succeed())
+		})
+	})
+
+	Context("With an application deploying the release of another application", func() {
+		var existing, application *Application
+
+		BeforeEach(func() {
+			existing = newTestApplication("web")
+			existing.Spec.ReleaseName = "web"
+			application = newTestApplication("web-copy")
+			application.Spec.ReleaseName = "web"
+			useObjects(existing, application)
+		})
+
+		It("Should refuse to create it", func() {
+			Expect(application.ValidateCreate()).To(MatchError(ContainSubstring("Release web in namespace web is already deployed by application team-a/web")))
+		})
+
+		It("Should let the controller record the collision and delete it", func() {
+			rejected := application.DeepCopy()
+			rejected.Status.State = Rejected
+			Expect(rejected.ValidateUpdate(application)).To(Succeed())
+
+			deleted := rejected.DeepCopy()
+			now := metav1.Now()
+			deleted.ObjectMeta.DeletionTimestamp = &now
+			Expect(deleted.ValidateUpdate(rejected)).To(Succeed())
+		})
+
+		It("Should accept spec changes keeping the release where it is", func() {
+			changed := application.DeepCopy()
+			changed.Spec.Template.Chart.Version = "1.2.3"
+			Expect(changed.ValidateUpdate(application)).To(Succeed())
+		})
+
+		It("Should refuse moving another release into its namespace", func() {
+			moved := newTestApplication("api")
+			moved.Spec.ReleaseName = "web"
+			moved.Spec.Template.Chart.Namespace = "api"
+			changed := moved.DeepCopy()
+			changed.Spec.Template.Chart.Namespace = "web"
+			Expect(changed.ValidateUpdate(moved)).To(MatchError(ContainSubstring("is already deployed by application team-a/web")))
+		})
+	})
+
+	Context("With a deployed release", func() {
+		var application *Application
+
+		BeforeEach(func() {
+			useObjects()
+			application = newTestApplication("web")
+			application.Default()
+		})
+
+		It("Should default the release name to the application name", func() {
+			Expect(application.Spec.ReleaseName).To(Equal("web"))
+		})
+
+		It("Should refuse changing the release name", func() {
+			changed := application.DeepCopy()
+			changed.Spec.ReleaseName = "web-v2"
+			Expect(changed.ValidateUpdate(application)).To(MatchError("Release name web cannot be changed to web-v2"))
+		})
+
+		It("Should accept naming the defaulted release name", func() {
+			created := application.DeepCopy()
+			created.Spec.ReleaseName = ""
+			named := created.DeepCopy()
+			named.Spec.ReleaseName = "web"
+			Expect(named.ValidateUpdate(created)).To(Succeed())
+		})
+
+		It("Should refuse changing the release name of a cluster application", func() {
+			clusterApplication := &ClusterApplication{
+				ObjectMeta: metav1.ObjectMeta{Name: "web"},
+				Spec:       application.Spec,
+			}
+			changed := clusterApplication.DeepCopy()
+			changed.Spec.ReleaseName = "web-v2"
+			Expect(changed.ValidateUpdate(clusterApplication)).To(MatchError("Release name web cannot be changed to web-v2"))
+		})
+	})
+
+	Context("With render validation", func() {
+		var application *Application
+		var renders int
+
+		BeforeEach(func() {
+			useObjects()
+			application = newTestApplication("web")
+			renders = 0
+			webhookOptions.RenderTimeout = time.Second
+			webhookOptions.ValidateRender = func(ctx context.Context, application *Application) error {
+				renders++
+				return nil
+			}
+		})
+
+		It("Should render created applications", func() {
+			Expect(application.ValidateCreate()).To(Succeed())
+			Expect(renders).To(Equal(1))
+		})
+
+		It("Should not render again when the spec is unchanged", func() {
+			updated := application.DeepCopy()
+			updated.Status.State = Deployed
+			updated.ObjectMeta.Labels = map[string]string{"team": "a"}
+			Expect(updated.ValidateUpdate(application)).To(Succeed())
+			Expect(renders).To(Equal(0))
+		})
+
+		It("Should stop rendering once the time budget is spent", func() {
+			stopped := make(chan struct{})
+			webhookOptions.RenderTimeout = 10 * time.Millisecond
+			webhookOptions.ValidateRender = func(ctx context.Context, application *Application) error {
+				<-ctx.Done()
+				close(stopped)
+				return ctx.Err()
+			}
+			Expect(application.ValidateCreate()).To(MatchError("Timed out rendering chart web after 10ms"))
+			Eventually(stopped).Should(BeClosed())
+		})
+	})
+	Context("With chart resolution", func() {
+		var application *Application
+
+		BeforeEach(func() {
+			useObjects()
+			application = newTestApplication("web")
+		})
+
+		It("Should cancel the download once the time budget is spent", func() {
+			cancelled := make(chan struct{})
+			webhookOptions.ResolveTimeout = 10 * time.Millisecond
+			webhookOptions.ResolveChart = func(ctx context.Context, chartName string, chartRepo string, version string, username string, password string) (string, error) {
+				<-ctx.Done()
+				close(cancelled)
+				return "", ctx.Err()
+			}
+			Expect(application.ValidateCreate()).To(MatchError("Timed out resolving chart web from repository https://charts.example.com/ after 10ms"))
+			Eventually(cancelled).Should(BeClosed())
+		})
+	})
+})
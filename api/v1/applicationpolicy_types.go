@@ -0,0 +1,128 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApplicationPolicySpec defines what applications of the source namespaces are allowed to deploy.
+// In patterns * matches any characters , e.g. team-a-* or https://charts.example.com/* , and an empty list allows everything.
+type ApplicationPolicySpec struct {
+	// Namespaces of the applications the policy applies to , all namespaces when empty
+	// +optional
+	SourceNamespaces []string `json:"sourceNamespaces,omitempty"`
+
+	// Namespaces the charts may be deployed to
+	// +optional
+	TargetNamespaces []string `json:"targetNamespaces,omitempty"`
+
+	// Repository urls the charts may be fetched from
+	// +optional
+	ChartRepos []string `json:"chartRepos,omitempty"`
+
+	// Names of the charts that may be deployed
+	// +optional
+	Charts []string `json:"charts,omitempty"`
+
+	// Kinds of the federated objects the charts may generate , e.g. FederatedDeployment
+	// +optional
+	FederatedKinds []string `json:"federatedKinds,omitempty"`
+
+	// Member clusters the federated objects may be placed on
+	// +optional
+	Clusters []string `json:"clusters,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// ApplicationPolicy is the Schema for the applicationpolicies API
+type ApplicationPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ApplicationPolicySpec `json:"spec,omitempty"`
+}
+
+// AppliesTo tells if the policy constrains applications of the namespace
+func (policy *ApplicationPolicy) AppliesTo(namespace string) bool {
+	return matchesAny(policy.Spec.SourceNamespaces, namespace)
+}
+
+// Violations lists what the application deploys that the policy does not allow ,
+// the generated federated kinds are only known after rendering and checked with AllowsKind
+func (policy *ApplicationPolicy) Violations(application *Application) []string {
+	var violations []string
+	chart := application.Spec.Template.Chart
+	if !matchesAny(policy.Spec.TargetNamespaces, chart.Namespace) {
+		violations = append(violations, fmt.Sprintf("target namespace %s is not allowed by policy %s", chart.Namespace, policy.Name))
+	}
+	if !matchesAny(policy.Spec.ChartRepos, chart.Repo) {
+		violations = append(violations, fmt.Sprintf("chart repository %s is not allowed by policy %s", chart.Repo, policy.Name))
+	}
+	if !matchesAny(policy.Spec.Charts, chart.Name) {
+		violations = append(violations, fmt.Sprintf("chart %s is not allowed by policy %s", chart.Name, policy.Name))
+	}
+	if strategy := application.Spec.RolloutStrategy; strategy != nil {
+		for _, step := range strategy.Steps {
+			for _, cluster := range step.Clusters {
+				if !policy.AllowsCluster(cluster) {
+					violations = append(violations, fmt.Sprintf("cluster %s is not allowed by policy %s", cluster, policy.Name))
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// AllowsKind tells if the application may generate federated objects of the kind
+func (policy *ApplicationPolicy) AllowsKind(kind string) bool {
+	return matchesAny(policy.Spec.FederatedKinds, kind)
+}
+
+// AllowsCluster tells if federated objects may be placed on the member cluster
+func (policy *ApplicationPolicy) AllowsCluster(cluster string) bool {
+	return matchesAny(policy.Spec.Clusters, cluster)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matchPattern(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// +kubebuilder:object:root=true
+
+// ApplicationPolicyList contains a list of ApplicationPolicy
+type ApplicationPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ApplicationPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ApplicationPolicy{}, &ApplicationPolicyList{})
+}
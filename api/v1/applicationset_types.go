@@ -0,0 +1,146 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ApplicationSetLabel is set on every application generated by an application set
+const ApplicationSetLabel = "federation.kubefed.fulliautomatix.site/application-set"
+
+// ListGenerator produces one parameter set per element
+type ListGenerator struct {
+	// Parameter sets , e.g. an environment name and its replica count
+	Elements []map[string]string `json:"elements"`
+}
+
+// ClusterGenerator produces one parameter set per member cluster , with the parameters name ,
+// apiEndpoint and labels.<key> of its KubeFedCluster
+type ClusterGenerator struct {
+	// KubeFedClusters to generate applications for , all member clusters when empty
+	// +optional
+	Selector metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// ApplicationSetBaseGenerator is a generator that can be combined in a matrix
+type ApplicationSetBaseGenerator struct {
+	// +optional
+	List *ListGenerator `json:"list,omitempty"`
+
+	// +optional
+	Clusters *ClusterGenerator `json:"clusters,omitempty"`
+}
+
+// MatrixGenerator produces every combination of the parameter sets of its generators
+type MatrixGenerator struct {
+	// +kubebuilder:validation:MinItems=2
+	Generators []ApplicationSetBaseGenerator `json:"generators"`
+}
+
+// ApplicationSetGenerator produces parameter sets , exactly one generator must be set
+type ApplicationSetGenerator struct {
+	// +optional
+	List *ListGenerator `json:"list,omitempty"`
+
+	// +optional
+	Clusters *ClusterGenerator `json:"clusters,omitempty"`
+
+	// +optional
+	Matrix *MatrixGenerator `json:"matrix,omitempty"`
+}
+
+// ApplicationSetTemplateMeta is the metadata of the generated applications
+type ApplicationSetTemplateMeta struct {
+	// Name of the generated applications , must contain parameters making it unique , e.g. web-{{name}}
+	Name string `json:"name"`
+
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ApplicationSetTemplate is the application generated for every parameter set ,
+// {{parameter}} in any string is replaced by the value of the parameter , other {{ }} text is kept
+type ApplicationSetTemplate struct {
+	Metadata ApplicationSetTemplateMeta `json:"metadata"`
+
+	Spec ApplicationSpec `json:"spec"`
+}
+
+// ApplicationSetSpec defines the desired state of ApplicationSet
+type ApplicationSetSpec struct {
+	// Generators whose parameter sets are all generated
+	// +kubebuilder:validation:MinItems=1
+	Generators []ApplicationSetGenerator `json:"generators"`
+
+	Template ApplicationSetTemplate `json:"template"`
+}
+
+// ApplicationSetStatus defines the observed state of ApplicationSet
+type ApplicationSetStatus struct {
+	// Names of the generated applications
+	// +optional
+	Applications []string `json:"applications,omitempty"`
+
+	// Why the applications could not be generated
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ApplicationSet generates applications from a template for every parameter set of its generators
+type ApplicationSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApplicationSetSpec   `json:"spec,omitempty"`
+	Status ApplicationSetStatus `json:"status,omitempty"`
+}
+
+// UsesClusters tells if the generated applications depend on the member clusters
+func (r *ApplicationSet) UsesClusters() bool {
+	for _, generator := range r.Spec.Generators {
+		if generator.Clusters != nil {
+			return true
+		}
+		if generator.Matrix != nil {
+			for _, nested := range generator.Matrix.Generators {
+				if nested.Clusters != nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// +kubebuilder:object:root=true
+
+// ApplicationSetList contains a list of ApplicationSet
+type ApplicationSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ApplicationSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ApplicationSet{}, &ApplicationSetList{})
+}
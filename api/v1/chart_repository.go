@@ -0,0 +1,71 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ChartRepository is the resolved repository of a chart
+// +kubebuilder:object:generate=false
+type ChartRepository struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// ResolveChartRepository returns the repository url of the chart , or the url and credentials of the
+// HelmRepository it references in the namespace of the application
+func ResolveChartRepository(reader client.Reader, namespace string, chart HelmChartSpec) (ChartRepository, error) {
+	if chart.RepositoryRef == "" {
+		return ChartRepository{URL: chart.Repo}, nil
+	}
+	if namespace == "" {
+		return ChartRepository{}, fmt.Errorf("Cluster applications cannot reference HelmRepository %s , set repoUrl instead", chart.RepositoryRef)
+	}
+	if reader == nil {
+		return ChartRepository{}, fmt.Errorf("HelmRepository %s cannot be resolved without a cluster , set repoUrl instead", chart.RepositoryRef)
+	}
+	var repository HelmRepository
+	if err := reader.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: chart.RepositoryRef}, &repository); err != nil {
+		return ChartRepository{}, fmt.Errorf("Unable to fetch HelmRepository %s: %v", chart.RepositoryRef, err)
+	}
+	resolved := ChartRepository{URL: repository.Spec.URL}
+	if repository.Spec.SecretRef == nil {
+		return resolved, nil
+	}
+	var secret corev1.Secret
+	if err := reader.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: repository.Spec.SecretRef.Name}, &secret); err != nil {
+		return ChartRepository{}, fmt.Errorf("Unable to fetch the credentials of HelmRepository %s: %v", chart.RepositoryRef, err)
+	}
+	resolved.Username = string(secret.Data[HelmRepositoryUsernameKey])
+	resolved.Password = string(secret.Data[HelmRepositoryPasswordKey])
+	return resolved, nil
+}
+
+// WithChartRepository is a copy of the application rendering the chart from the resolved repository url
+func (r *Application) WithChartRepository(repository ChartRepository) *Application {
+	resolved := r.DeepCopy()
+	resolved.Spec.Template.Chart.Repo = repository.URL
+	resolved.Spec.Template.Chart.RepositoryRef = ""
+	return resolved
+}
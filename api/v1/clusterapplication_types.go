@@ -0,0 +1,69 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+
+// ClusterApplication is a platform wide application not tied to a namespace , e.g. an add-on deployed
+// to every member cluster
+type ClusterApplication struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApplicationSpec   `json:"spec,omitempty"`
+	Status ApplicationStatus `json:"status,omitempty"`
+}
+
+// Application is the cluster application as the namespaced application it is reconciled as ,
+// sharing its metadata , spec and status with an empty namespace
+func (r *ClusterApplication) Application() *Application {
+	application := &Application{
+		TypeMeta:   metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "Application"},
+		ObjectMeta: *r.ObjectMeta.DeepCopy(),
+		Spec:       *r.Spec.DeepCopy(),
+		Status:     *r.Status.DeepCopy(),
+	}
+	application.Namespace = ""
+	return application
+}
+
+// ReleaseName is the helm release name the chart is rendered with
+func (r *ClusterApplication) ReleaseName() string {
+	if r.Spec.ReleaseName != "" {
+		return r.Spec.ReleaseName
+	}
+	return r.Name
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterApplicationList contains a list of ClusterApplication
+type ClusterApplicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterApplication `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterApplication{}, &ClusterApplicationList{})
+}
@@ -0,0 +1,87 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// SetupWebhookWithManager registers the webhooks of cluster applications , sharing the options
+// of the application webhooks
+func (r *ClusterApplication) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-federation-kubefed-fulliautomatix-site-v1-clusterapplication,mutating=true,failurePolicy=fail,groups=federation.kubefed.fulliautomatix.site,resources=clusterapplications,verbs=create;update,versions=v1,name=mclusterapplication.kb.io
+
+var _ webhook.Defaulter = &ClusterApplication{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *ClusterApplication) Default() {
+	applicationlog.Info("default cluster application", "name", r.Name)
+
+	application := r.Application()
+	application.Default()
+	r.Spec = application.Spec
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-federation-kubefed-fulliautomatix-site-v1-clusterapplication,mutating=false,failurePolicy=fail,groups=federation.kubefed.fulliautomatix.site,resources=clusterapplications,versions=v1,name=vclusterapplication.kb.io
+
+var _ webhook.Validator = &ClusterApplication{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type ,
+// cluster applications are not constrained by the application policies of any namespace
+func (r *ClusterApplication) ValidateCreate() error {
+	applicationlog.Info("validate create cluster application", "name", r.Name)
+	application := r.Application()
+	if err := application.validateApplication(); err != nil {
+		return err
+	}
+	return application.validateReleaseCollision()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterApplication) ValidateUpdate(old runtime.Object) error {
+	applicationlog.Info("validate update cluster application", "name", r.Name)
+	application := r.Application()
+	if oldApplication, ok := old.(*ClusterApplication); ok && specUnchanged(oldApplication.Application(), application) {
+		return nil
+	}
+	if err := application.validateApplication(); err != nil {
+		return err
+	}
+	if oldApplication, ok := old.(*ClusterApplication); ok && oldApplication.ReleaseName() != r.ReleaseName() {
+		return fmt.Errorf("Release name %s cannot be changed to %s", oldApplication.ReleaseName(), r.ReleaseName())
+	}
+	if oldApplication, ok := old.(*ClusterApplication); ok && !releaseMoved(oldApplication.Application(), application) {
+		return nil
+	}
+	return application.validateReleaseCollision()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *ClusterApplication) ValidateDelete() error {
+	applicationlog.Info("validate delete cluster application", "name", r.Name)
+
+	return nil
+}
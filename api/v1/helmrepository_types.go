@@ -0,0 +1,95 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// HelmRepositoryUsernameKey is the key of the basic auth username in the credentials Secret
+	HelmRepositoryUsernameKey = "username"
+	// HelmRepositoryPasswordKey is the key of the basic auth password in the credentials Secret
+	HelmRepositoryPasswordKey = "password"
+)
+
+// HelmRepositorySpec defines the desired state of HelmRepository
+type HelmRepositorySpec struct {
+	// Url of the chart repository
+	// +kubebuilder:validation:Required
+	URL string `json:"url"`
+
+	// Secret in the namespace of the repository with the basic auth username and password keys
+	// +optional
+	SecretRef *corev1.LocalObjectReference `json:"secretRef,omitempty"`
+
+	// How often the index of the repository is refreshed , defaults to 10m
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// RepositoryChart is a chart available in the repository
+type RepositoryChart struct {
+	Name string `json:"name"`
+
+	// Latest versions of the chart , newest first
+	Versions []string `json:"versions"`
+}
+
+// HelmRepositoryStatus defines the observed state of HelmRepository
+type HelmRepositoryStatus struct {
+	// Charts of the repository index
+	// +optional
+	Charts []RepositoryChart `json:"charts,omitempty"`
+
+	// Digest of the last fetched index , applications referencing the repository are reconciled when it changes
+	// +optional
+	IndexDigest string `json:"indexDigest,omitempty"`
+
+	// +optional
+	LastRefreshed *metav1.Time `json:"lastRefreshed,omitempty"`
+
+	// Why the index could not be refreshed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// HelmRepository is a chart repository applications of its namespace can reference
+type HelmRepository struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HelmRepositorySpec   `json:"spec,omitempty"`
+	Status HelmRepositoryStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HelmRepositoryList contains a list of HelmRepository
+type HelmRepositoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HelmRepository `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HelmRepository{}, &HelmRepositoryList{})
+}
@@ -0,0 +1,93 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Patterns", func() {
+	table.DescribeTable("matchPattern",
+		func(pattern string, value string, expected bool) {
+			Expect(matchPattern(pattern, value)).To(Equal(expected))
+		},
+		table.Entry("exact value", "team-a", "team-a", true),
+		table.Entry("other value", "team-a", "team-b", false),
+		table.Entry("prefix", "team-*", "team-a", true),
+		table.Entry("prefix mismatch", "team-*", "ops-a", false),
+		table.Entry("nested path", "https://charts.example.com/*", "https://charts.example.com/stable/web", true),
+		table.Entry("other host", "https://charts.example.com/*", "https://charts.example.org/stable", false),
+		table.Entry("trailing slash", "https://charts.example.com", "https://charts.example.com/", true),
+		table.Entry("middle wildcard", "https://*.example.com/charts", "https://eu.charts.example.com/charts", true),
+		table.Entry("middle wildcard mismatch", "https://*.example.com/charts", "https://eu.example.com/other", false),
+		table.Entry("overlapping prefix and suffix", "ab*ba", "aba", false),
+	)
+
+	It("should allow charts from nested repository paths", func() {
+		policy := &ApplicationPolicy{Spec: ApplicationPolicySpec{ChartRepos: []string{"https://charts.example.com/*"}}}
+		Expect(matchesAny(policy.Spec.ChartRepos, "https://charts.example.com/stable/web")).To(BeTrue())
+		Expect(matchesAny(policy.Spec.ChartRepos, "https://charts.example.org/web")).To(BeFalse())
+	})
+
+	table.DescribeTable("RepositoryPolicy",
+		func(policy RepositoryPolicy, repo string, allowed bool) {
+			if err := policy.Check(repo); allowed {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				Expect(err).To(HaveOccurred())
+			}
+		},
+		table.Entry("empty policy allows", RepositoryPolicy{}, "https://charts.example.com/", true),
+		table.Entry("allowed nested path", RepositoryPolicy{Allowed: []string{"https://charts.example.com/*"}}, "https://charts.example.com/stable/web", true),
+		table.Entry("not allowed", RepositoryPolicy{Allowed: []string{"https://charts.example.com/*"}}, "https://charts.example.org/", false),
+		table.Entry("denied", RepositoryPolicy{Denied: []string{"http://*"}}, "http://charts.example.com/", false),
+		table.Entry("not denied", RepositoryPolicy{Denied: []string{"http://*"}}, "https://charts.example.com/", true),
+		table.Entry("deny takes precedence", RepositoryPolicy{
+			Allowed: []string{"https://charts.example.com/*"},
+			Denied:  []string{"https://charts.example.com/incubator/*"},
+		}, "https://charts.example.com/incubator/web", false),
+		table.Entry("allow beside deny", RepositoryPolicy{
+			Allowed: []string{"https://charts.example.com/*"},
+			Denied:  []string{"https://charts.example.com/incubator/*"},
+		}, "https://charts.example.com/stable/web", true),
+	)
+
+	It("Should add the patterns of the repository policy ConfigMap", func() {
+		useObjects(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "kubefed-system", Name: "repositories"},
+			Data:       map[string]string{"denied": "http://*"},
+		})
+		defer func() {
+			applicationReader = nil
+			apiReader = nil
+		}()
+		base := RepositoryPolicy{Allowed: []string{"https://charts.example.com/*"}}
+		policy, err := LoadRepositoryPolicy(apiReader, base, types.NamespacedName{Namespace: "kubefed-system", Name: "repositories"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(policy.Allowed).To(Equal(base.Allowed))
+		Expect(policy.Denied).To(Equal([]string{"http://*"}))
+
+		missing, err := LoadRepositoryPolicy(apiReader, base, types.NamespacedName{Namespace: "kubefed-system", Name: "missing"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(missing).To(Equal(base))
+	})
+})
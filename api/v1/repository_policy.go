@@ -0,0 +1,67 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RepositoryPolicy lists the chart repository url patterns applications may use , matched like ApplicationPolicy patterns.
+// Denied patterns take precedence and an empty allowed list allows every repository.
+// +kubebuilder:object:generate=false
+type RepositoryPolicy struct {
+	Allowed []string
+	Denied  []string
+}
+
+// ParseRepositoryPatterns splits comma or newline separated repository url patterns
+func ParseRepositoryPatterns(patterns string) []string {
+	var result []string
+	for _, pattern := range strings.FieldsFunc(patterns, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			result = append(result, pattern)
+		}
+	}
+	return result
+}
+
+// Merge returns the patterns of both policies
+func (policy RepositoryPolicy) Merge(other RepositoryPolicy) RepositoryPolicy {
+	return RepositoryPolicy{
+		Allowed: append(append([]string(nil), policy.Allowed...), other.Allowed...),
+		Denied:  append(append([]string(nil), policy.Denied...), other.Denied...),
+	}
+}
+
+// Check fails when the repository url is denied or not allowed
+func (policy RepositoryPolicy) Check(repo string) error {
+	for _, pattern := range policy.Denied {
+		if matchPattern(pattern, repo) {
+			return fmt.Errorf("Chart repository %s is denied by pattern %s", repo, pattern)
+		}
+	}
+	if len(policy.Allowed) == 0 {
+		return nil
+	}
+	for _, pattern := range policy.Allowed {
+		if matchPattern(pattern, repo) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Chart repository %s is not one of the allowed repositories %s", repo, strings.Join(policy.Allowed, ","))
+}
@@ -0,0 +1,104 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	federationv1 "kubefed-application-controller/api/v1"
+)
+
+var _ conversion.Convertible = &Application{}
+
+// ConvertTo converts the application to the v1 hub version
+func (src *Application) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*federationv1.Application)
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	spec := src.Spec.DeepCopy()
+
+	dst.Spec = federationv1.ApplicationSpec{
+		Type: spec.Source.Type,
+		Template: federationv1.ApplicationTemplateSpec{
+			Chart: federationv1.HelmChartSpec{
+				Name:          spec.Source.Chart.Name,
+				Namespace:     spec.Placement.Namespace,
+				Repo:          spec.Source.Chart.Repo,
+				RepositoryRef: spec.Source.Chart.RepositoryRef,
+				Version:       spec.Source.Chart.Version,
+				Values:        spec.Render.Values,
+			},
+		},
+		ReleaseName:          spec.Render.ReleaseName,
+		ServiceAccountName:   spec.Placement.ServiceAccountName,
+		KubeVersion:          spec.Render.KubeVersion,
+		APIVersions:          spec.Render.APIVersions,
+		Suspend:              spec.Rollout.Suspend,
+		DryRun:               spec.Rollout.DryRun,
+		Rollback:             spec.Rollout.Rollback,
+		RevisionHistoryLimit: spec.Rollout.RevisionHistoryLimit,
+		RollbackTo:           spec.Rollout.RollbackTo,
+		RolloutStrategy:      spec.Rollout.Strategy,
+		DriftPolicy:          spec.Placement.DriftPolicy,
+		ApplyPolicy:          spec.Placement.ApplyPolicy,
+		AdoptionPolicy:       spec.Placement.AdoptionPolicy,
+	}
+	src.Status.DeepCopyInto(&dst.Status)
+	return nil
+}
+
+// ConvertFrom converts the v1 hub version to this version
+func (dst *Application) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*federationv1.Application)
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	spec := src.Spec.DeepCopy()
+	chart := spec.Template.Chart
+
+	dst.Spec = ApplicationSpec{
+		Source: ApplicationSource{
+			Type: spec.Type,
+			Chart: ChartSource{
+				Name:          chart.Name,
+				Repo:          chart.Repo,
+				RepositoryRef: chart.RepositoryRef,
+				Version:       chart.Version,
+			},
+		},
+		Render: RenderSpec{
+			ReleaseName: spec.ReleaseName,
+			Values:      chart.Values,
+			KubeVersion: spec.KubeVersion,
+			APIVersions: spec.APIVersions,
+		},
+		Placement: PlacementSpec{
+			Namespace:          chart.Namespace,
+			ServiceAccountName: spec.ServiceAccountName,
+			ApplyPolicy:        spec.ApplyPolicy,
+			AdoptionPolicy:     spec.AdoptionPolicy,
+			DriftPolicy:        spec.DriftPolicy,
+		},
+		Rollout: RolloutSpec{
+			Suspend:              spec.Suspend,
+			DryRun:               spec.DryRun,
+			Strategy:             spec.RolloutStrategy,
+			Rollback:             spec.Rollback,
+			RollbackTo:           spec.RollbackTo,
+			RevisionHistoryLimit: spec.RevisionHistoryLimit,
+		},
+	}
+	src.Status.DeepCopyInto(&dst.Status)
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	federationv1 "kubefed-application-controller/api/v1"
+)
+
+var _ = Describe("Application conversion", func() {
+	rollbackTo := 2
+	historyLimit := int32(5)
+	metadata := metav1.ObjectMeta{
+		Name:        "web",
+		Namespace:   "team-a",
+		Labels:      map[string]string{"team": "a"},
+		Annotations: map[string]string{federationv1.PreviewAnnotation: "true"},
+	}
+	status := federationv1.ApplicationStatus{
+		State:           federationv1.Deployed,
+		Health:          federationv1.Healthy,
+		CurrentRevision: 3,
+		Clusters:        []federationv1.ClusterStatus{{Name: "cluster-a", Health: federationv1.Healthy}},
+	}
+
+	It("Should keep every field of a v1 application through v2", func() {
+		original := &federationv1.Application{
+			ObjectMeta: metadata,
+			Spec: federationv1.ApplicationSpec{
+				Type: federationv1.Helm,
+				Template: federationv1.ApplicationTemplateSpec{
+					Chart: federationv1.HelmChartSpec{
+						Name:          "web",
+						Namespace:     "web",
+						Repo:          "https://charts.example.com/",
+						RepositoryRef: "charts",
+						Version:       "1.2.3",
+						Values:        &runtime.RawExtension{Raw: []byte(`{"replicaCount":2}`)},
+					},
+				},
+				ReleaseName:          "web-release",
+				ServiceAccountName:   "deployer",
+				KubeVersion:          "v1.18.0",
+				APIVersions:          []string{"networking.k8s.io/v1/Ingress"},
+				Suspend:              true,
+				DryRun:               true,
+				Rollback:             &federationv1.RollbackPolicy{Enabled: true, FailureThreshold: 2},
+				RevisionHistoryLimit: &historyLimit,
+				RollbackTo:           &rollbackTo,
+				RolloutStrategy: &federationv1.RolloutStrategy{Steps: []federationv1.RolloutStep{
+					{Clusters: []string{"canary"}, Pause: true},
+					{Percentage: 50},
+				}},
+				DriftPolicy:    federationv1.DriftReport,
+				ApplyPolicy:    &federationv1.ApplyPolicy{Force: true, ReleaseFields: []federationv1.ReleasedField{{Path: ".spec.replicas"}}},
+				AdoptionPolicy: federationv1.AdoptIfUnowned,
+			},
+			Status: status,
+		}
+
+		converted := &Application{}
+		Expect(converted.ConvertFrom(original.DeepCopy())).To(Succeed())
+		Expect(converted.Spec.Source.Chart.Repo).To(Equal("https://charts.example.com/"))
+		Expect(converted.Spec.Placement.Namespace).To(Equal("web"))
+		Expect(converted.Spec.Rollout.Strategy.Steps).To(HaveLen(2))
+
+		roundTripped := &federationv1.Application{}
+		Expect(converted.ConvertTo(roundTripped)).To(Succeed())
+		Expect(roundTripped).To(Equal(original))
+	})
+
+	It("Should keep every field of a v2 application through v1", func() {
+		original := &Application{
+			ObjectMeta: metadata,
+			Spec: ApplicationSpec{
+				Source: ApplicationSource{
+					Type:  federationv1.Helm,
+					Chart: ChartSource{Name: "web", RepositoryRef: "charts", Version: "1.2.3"},
+				},
+				Render: RenderSpec{
+					ReleaseName: "web-release",
+					Values:      &runtime.RawExtension{Raw: []byte(`{"image":{"tag":"1.0"}}`)},
+					KubeVersion: "v1.18.0",
+					APIVersions: []string{"networking.k8s.io/v1/Ingress"},
+				},
+				Placement: PlacementSpec{
+					Namespace:          "web",
+					ServiceAccountName: "deployer",
+					ApplyPolicy:        &federationv1.ApplyPolicy{ReleaseFields: []federationv1.ReleasedField{{Kind: "FederatedDeployment", Path: ".spec.replicas"}}},
+					AdoptionPolicy:     federationv1.AdoptAlways,
+					DriftPolicy:        federationv1.DriftCorrect,
+				},
+				Rollout: RolloutSpec{
+					Suspend:              true,
+					Strategy:             &federationv1.RolloutStrategy{Steps: []federationv1.RolloutStep{{Percentage: 25}}},
+					Rollback:             &federationv1.RollbackPolicy{Enabled: true, HealthTimeout: &metav1.Duration{Duration: 60}},
+					RollbackTo:           &rollbackTo,
+					RevisionHistoryLimit: &historyLimit,
+				},
+			},
+			Status: status,
+		}
+
+		hub := &federationv1.Application{}
+		Expect(original.DeepCopy().ConvertTo(hub)).To(Succeed())
+		Expect(hub.Spec.Template.Chart.Name).To(Equal("web"))
+		Expect(hub.Spec.ReleaseName).To(Equal("web-release"))
+
+		roundTripped := &Application{}
+		Expect(roundTripped.ConvertFrom(hub)).To(Succeed())
+		Expect(roundTripped).To(Equal(original))
+	})
+
+	It("Should not share values with the converted application", func() {
+		original := &Application{Spec: ApplicationSpec{Render: RenderSpec{
+			Values: &runtime.RawExtension{Raw: []byte(`{"a":1}`)},
+		}}}
+		hub := &federationv1.Application{}
+		Expect(original.ConvertTo(hub)).To(Succeed())
+		hub.Spec.Template.Chart.Values.Raw[0] = '['
+		Expect(string(original.Spec.Render.Values.Raw)).To(Equal(`{"a":1}`))
+	})
+})
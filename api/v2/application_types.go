@@ -0,0 +1,168 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	federationv1 "kubefed-application-controller/api/v1"
+)
+
+// ApplicationSource defines where the application is fetched from
+type ApplicationSource struct {
+	// Defines an application type , by default it is Helm .
+	// +kubebuilder:validation:Required
+	Type federationv1.ApplicationType `json:"type"`
+
+	// +kubebuilder:validation:Required
+	Chart ChartSource `json:"chart"`
+}
+
+// ChartSource is a helm chart in a chart repository
+type ChartSource struct {
+	// Name of the helm chart
+	// +kubebuilder:validation:Required
+	Name string `json:"name"`
+
+	// Repository to fetch the helm chart from , required unless repositoryRef is set
+	// +optional
+	Repo string `json:"repoUrl,omitempty"`
+
+	// Name of a HelmRepository in the namespace of the application to fetch the helm chart from
+	// +optional
+	RepositoryRef string `json:"repositoryRef,omitempty"`
+
+	// Installing a specific version
+	// +optional
+	Version string `json:"version,omitempty"`
+}
+
+// RenderSpec defines how the chart is rendered
+type RenderSpec struct {
+	// Helm release name the chart is rendered with , defaults to the application name and cannot be changed
+	// +optional
+	ReleaseName string `json:"releaseName,omitempty"`
+
+	// Values overriding the defaults of the chart
+	// +optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Values *runtime.RawExtension `json:"values,omitempty"`
+
+	// Kubernetes version the chart is rendered against , discovered from the member clusters when
+	// neither kubeVersion nor apiVersions are set
+	// +optional
+	KubeVersion string `json:"kubeVersion,omitempty"`
+
+	// API versions available to the chart in addition to the helm defaults , e.g. networking.k8s.io/v1/Ingress
+	// +optional
+	APIVersions []string `json:"apiVersions,omitempty"`
+}
+
+// PlacementSpec defines where and how the federated objects are applied
+type PlacementSpec struct {
+	// Namespace where the chart artifacts should be deployed
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// ServiceAccount in the namespace of the application impersonated to apply the federated objects ,
+	// the controller's own account is used when empty
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// How the federated objects are server side applied
+	// +optional
+	ApplyPolicy *federationv1.ApplyPolicy `json:"applyPolicy,omitempty"`
+
+	// Whether existing federated objects not created by the application are taken over , defaults to Never
+	// +optional
+	AdoptionPolicy federationv1.AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+
+	// What to do when generated federated objects are changed or deleted , defaults to Correct
+	// +optional
+	DriftPolicy federationv1.DriftPolicy `json:"driftPolicy,omitempty"`
+}
+
+// RolloutSpec defines how revisions are rolled out and rolled back
+type RolloutSpec struct {
+	// Stop rendering and applying the application until it is resumed , deletion is still handled
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Only preview the changes to the federated objects in the status without applying them
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Roll out new revisions to the member clusters in steps instead of all at once
+	// +optional
+	Strategy *federationv1.RolloutStrategy `json:"strategy,omitempty"`
+
+	// Automatic rollback of failed deployments
+	// +optional
+	Rollback *federationv1.RollbackPolicy `json:"rollback,omitempty"`
+
+	// Re-deploy the exact rendered output of a previous revision instead of rendering the chart
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RollbackTo *int `json:"rollbackTo,omitempty"`
+
+	// Number of revisions to keep , defaults to 10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+}
+
+// ApplicationSpec defines the desired state of Application
+type ApplicationSpec struct {
+	// +kubebuilder:validation:Required
+	Source ApplicationSource `json:"source"`
+
+	// +optional
+	Render RenderSpec `json:"render,omitempty"`
+
+	// +optional
+	Placement PlacementSpec `json:"placement,omitempty"`
+
+	// +optional
+	Rollout RolloutSpec `json:"rollout,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+
+// Application is the Schema for the applications API
+type Application struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ApplicationSpec                `json:"spec,omitempty"`
+	Status federationv1.ApplicationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ApplicationList contains a list of Application
+type ApplicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Application `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Application{}, &ApplicationList{})
+}
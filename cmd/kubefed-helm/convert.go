@@ -0,0 +1,220 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/tools/clientcmd"
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+	"sigs.k8s.io/kubefed/pkg/client/generic"
+	ctlutil "sigs.k8s.io/kubefed/pkg/controller/util"
+	"sigs.k8s.io/yaml"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+// keepResourcePolicy stops helm from deleting an object when its release is uninstalled
+const keepResourcePolicy = "helm.sh/resource-policy"
+
+func runConvert(args []string) error {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	releaseName := flags.String("release", "", "Name of the helm release to convert.")
+	releaseNamespace := flags.String("release-namespace", "default", "Namespace of the helm release.")
+	releaseKubeconfig := flags.String("release-kubeconfig", "", "Kubeconfig of the cluster running the helm release , the default loading rules apply when empty.")
+	repo := flags.String("repo", "", "Repository url of the chart , helm does not record it in the release.")
+	namespace := flags.String("n", "", "Namespace of the generated Application , the release namespace when empty.")
+	adopt := flags.Bool("adopt", false, "Prepare the objects of the release to be adopted by KubeFed instead of recreated.")
+	kubeconfig := flags.String("kubeconfig", "", "Kubeconfig of the KubeFed host cluster , only used with -adopt.")
+	kubefedNamespace := flags.String("kubefed-namespace", ctlutil.DefaultKubeFedSystemNamespace, "Namespace of the KubeFed control plane , only used with -adopt.")
+	output := flags.String("o", "", "File to write the Application to , stdout when empty.")
+	flags.Parse(args)
+	if *releaseName == "" {
+		return fmt.Errorf("The release name is required , use -release")
+	}
+	if *repo == "" {
+		return fmt.Errorf("The chart repository is required , use -repo")
+	}
+
+	configFlags := genericclioptions.NewConfigFlags(true)
+	configFlags.KubeConfig = releaseKubeconfig
+	configFlags.Namespace = releaseNamespace
+	actionConfig, err := releaseConfiguration(configFlags, *releaseNamespace)
+	if err != nil {
+		return err
+	}
+	rel, err := latestRelease(actionConfig, *releaseName, *releaseNamespace)
+	if err != nil {
+		return err
+	}
+	if *namespace == "" {
+		namespace = releaseNamespace
+	}
+	application, err := releaseApplication(rel, *repo, *namespace)
+	if err != nil {
+		return err
+	}
+
+	if *adopt {
+		if err := checkAdoption(*kubeconfig, *kubefedNamespace); err != nil {
+			return err
+		}
+		if err := keepReleaseResources(actionConfig, rel); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "The objects of release %s are kept when it is uninstalled , uninstall it once the Application is deployed\n", rel.Name)
+	}
+
+	data, err := applicationYAML(application)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(*output, data, 0644)
+}
+
+// releaseConfiguration reads and writes helm releases of the namespace in the helm release storage Secrets
+func releaseConfiguration(configFlags *genericclioptions.ConfigFlags, namespace string) (*action.Configuration, error) {
+	actionConfig := new(action.Configuration)
+	if err := actionConfig.Init(configFlags, namespace, "secret", func(string, ...interface{}) {}); err != nil {
+		return nil, err
+	}
+	return actionConfig, nil
+}
+
+// latestRelease reads the latest revision of the release
+func latestRelease(actionConfig *action.Configuration, name string, namespace string) (*release.Release, error) {
+	rel, err := action.NewGet(actionConfig).Run(name)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read release %s/%s: %v", namespace, name, err)
+	}
+	if rel.Chart == nil || rel.Chart.Metadata == nil {
+		return nil, fmt.Errorf("Release %s/%s does not record its chart", namespace, name)
+	}
+	return rel, nil
+}
+
+// releaseApplication generates an Application deploying the chart of the release with its user supplied values
+func releaseApplication(rel *release.Release, repo string, namespace string) (*federationv1.Application, error) {
+	application := &federationv1.Application{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: federationv1.GroupVersion.String(),
+			Kind:       "Application",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rel.Name,
+			Namespace: namespace,
+		},
+		Spec: federationv1.ApplicationSpec{
+			Type:        federationv1.Helm,
+			ReleaseName: rel.Name,
+			Template: federationv1.ApplicationTemplateSpec{
+				Chart: federationv1.HelmChartSpec{
+					Name:      rel.Chart.Metadata.Name,
+					Namespace: rel.Namespace,
+					Repo:      repo,
+					Version:   rel.Chart.Metadata.Version,
+				},
+			},
+		},
+	}
+	if len(rel.Config) > 0 {
+		raw, err := json.Marshal(rel.Config)
+		if err != nil {
+			return nil, err
+		}
+		application.Spec.Template.Chart.Values = &runtime.RawExtension{Raw: raw}
+	}
+	return application, nil
+}
+
+// checkAdoption makes sure KubeFed adopts existing objects in member clusters instead of failing to create them
+func checkAdoption(kubeconfig string, kubefedNamespace string) error {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return fmt.Errorf("Unable to load kubeconfig: %v", err)
+	}
+	hostClient, err := generic.New(config)
+	if err != nil {
+		return err
+	}
+	kubefedConfig := &fedv1b1.KubeFedConfig{}
+	if err := hostClient.Get(context.TODO(), kubefedConfig, kubefedNamespace, ctlutil.KubeFedConfigName); err != nil {
+		return fmt.Errorf("Unable to read KubeFedConfig %s/%s: %v", kubefedNamespace, ctlutil.KubeFedConfigName, err)
+	}
+	syncController := kubefedConfig.Spec.SyncController
+	if syncController != nil && syncController.AdoptResources != nil && *syncController.AdoptResources == fedv1b1.AdoptResourcesDisabled {
+		return fmt.Errorf("KubeFed does not adopt existing resources , enable spec.syncController.adoptResources of KubeFedConfig %s/%s", kubefedNamespace, ctlutil.KubeFedConfigName)
+	}
+	return nil
+}
+
+// keepReleaseResources marks the objects in the stored manifest of the release , which is what helm reads
+// when uninstalling it , so uninstalling the release leaves them to KubeFed
+func keepReleaseResources(actionConfig *action.Configuration, rel *release.Release) error {
+	resources, err := util.ParseManifest(&rel.Manifest)
+	if err != nil {
+		return fmt.Errorf("Unable to parse the manifest of release %s: %v", rel.Name, err)
+	}
+	manifest := ""
+	for _, resource := range resources {
+		annotations := resource.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[keepResourcePolicy] = "keep"
+		resource.SetAnnotations(annotations)
+		data, err := yaml.Marshal(resource.Object)
+		if err != nil {
+			return err
+		}
+		manifest += "---\n" + string(data)
+	}
+	rel.Manifest = manifest
+	if err := actionConfig.Releases.Update(rel); err != nil {
+		return fmt.Errorf("Unable to update release %s/%s: %v", rel.Namespace, rel.Name, err)
+	}
+	return nil
+}
+
+// applicationYAML serializes the Application without its status
+func applicationYAML(application *federationv1.Application) ([]byte, error) {
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(application)
+	if err != nil {
+		return nil, err
+	}
+	delete(object, "status")
+	unstructured.RemoveNestedField(object, "metadata", "creationTimestamp")
+	return yaml.Marshal(object)
+}
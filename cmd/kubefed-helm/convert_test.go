@@ -0,0 +1,133 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// deletingKubeClient records the manifests helm deletes
+type deletingKubeClient struct {
+	kubefake.PrintingKubeClient
+	deleted string
+}
+
+func (client *deletingKubeClient) Build(reader io.Reader, validate bool) (kube.ResourceList, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	client.deleted += string(data)
+	return client.PrintingKubeClient.Build(reader, validate)
+}
+
+const releaseManifest = `---
+# Source: web/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+data:
+  greeting: hello
+---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  annotations:
+    team: web
+spec:
+  ports:
+  - port: 80
+`
+
+var _ = Describe("convert", func() {
+	var (
+		kubeClient   *deletingKubeClient
+		actionConfig *action.Configuration
+		rel          *release.Release
+	)
+
+	BeforeEach(func() {
+		kubeClient = &deletingKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}}
+		actionConfig = &action.Configuration{
+			Releases:     storage.Init(driver.NewMemory()),
+			KubeClient:   kubeClient,
+			Capabilities: chartutil.DefaultCapabilities,
+			Log:          func(string, ...interface{}) {},
+		}
+		rel = &release.Release{
+			Name:      "web",
+			Namespace: "apps",
+			Version:   1,
+			Info:      &release.Info{Status: release.StatusDeployed},
+			Chart:     &chart.Chart{Metadata: &chart.Metadata{APIVersion: "v2", Name: "web", Version: "0.1.0"}},
+			Config:    map[string]interface{}{"replicas": float64(2)},
+			Manifest:  releaseManifest,
+		}
+		Expect(actionConfig.Releases.Create(rel)).To(Succeed())
+	})
+
+	It("Should generate an Application deploying the chart of the release", func() {
+		latest, err := latestRelease(actionConfig, "web", "apps")
+		Expect(err).ToNot(HaveOccurred())
+		application, err := releaseApplication(latest, "https://charts.example.com/", "team-web")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(application.Namespace).To(Equal("team-web"))
+		Expect(application.Spec.ReleaseName).To(Equal("web"))
+		Expect(application.Spec.Template.Chart.Name).To(Equal("web"))
+		Expect(application.Spec.Template.Chart.Namespace).To(Equal("apps"))
+		Expect(application.Spec.Template.Chart.Version).To(Equal("0.1.0"))
+		Expect(string(application.Spec.Template.Chart.Values.Raw)).To(Equal(`{"replicas":2}`))
+	})
+
+	It("Should keep the objects of the release when it is uninstalled", func() {
+		latest, err := latestRelease(actionConfig, "web", "apps")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(keepReleaseResources(actionConfig, latest)).To(Succeed())
+
+		stored, err := actionConfig.Releases.Get("web", 1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Count(stored.Manifest, keepResourcePolicy+": keep")).To(Equal(2))
+		Expect(stored.Manifest).To(ContainSubstring("team: web"))
+
+		_, err = action.NewUninstall(actionConfig).Run("web")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kubeClient.deleted).ToNot(ContainSubstring("web-config"))
+		Expect(kubeClient.deleted).ToNot(ContainSubstring("kind: Service"))
+	})
+
+	It("Should delete the objects of an unconverted release when it is uninstalled", func() {
+		_, err := action.NewUninstall(actionConfig).Run("web")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(kubeClient.deleted).To(ContainSubstring("web-config"))
+	})
+})
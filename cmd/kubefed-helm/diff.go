@@ -0,0 +1,196 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pmezard/go-difflib/difflib"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+
+	"kubefed-application-controller/controllers"
+	"kubefed-application-controller/controllers/util"
+)
+
+// errChanges is returned by diff when the live federated objects differ from the Application
+var errChanges = errors.New("")
+
+func runDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	filename := flags.String("f", "", "The Application manifest to compare with the host cluster.")
+	kubeconfig := flags.String("kubeconfig", "", "Kubeconfig of the KubeFed host cluster , the default loading rules apply when empty.")
+	namespace := flags.String("n", "", "Namespace of the Application when not set in the manifest , the kubeconfig namespace when empty.")
+	var values valueFiles
+	flags.Var(&values, "values", "Local values file overriding the values of the Application , can be repeated.")
+	flags.Parse(args)
+	if *filename == "" {
+		return fmt.Errorf("The Application manifest is required , use -f")
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = *kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("Unable to load kubeconfig: %v", err)
+	}
+
+	application, err := readApplication(*filename)
+	if err != nil {
+		return err
+	}
+	if application.Namespace == "" {
+		application.Namespace = *namespace
+	}
+	if application.Namespace == "" {
+		if application.Namespace, _, err = clientConfig.Namespace(); err != nil {
+			return err
+		}
+	}
+	federatedManifest, err := renderApplication(application, values)
+	if err != nil {
+		return err
+	}
+	fedResources, err := util.ParseManifest(federatedManifest)
+	if err != nil {
+		return err
+	}
+	controllers.PrepareFederatedResources(application, fedResources)
+
+	deployer, err := util.NewServerSideDeployer(config)
+	if err != nil {
+		return fmt.Errorf("Unable to create a dynamic client: %v", err)
+	}
+	// the dry run must not fail on fields owned by other managers
+	deployer.Force = true
+
+	changed, err := diffResources(deployer, fedResources, application.Spec.Template.Chart.Namespace, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if changed {
+		return errChanges
+	}
+	return nil
+}
+
+// dryRunClient reads the live federated objects and dry run applies the federated resources
+type dryRunClient interface {
+	Get(resourceObj unstructured.Unstructured, namespace string) (*unstructured.Unstructured, error)
+	DryRunApply(resourceObj unstructured.Unstructured, namespace string) (*unstructured.Unstructured, error)
+}
+
+// diffResources writes the diff of every federated resource with its live object , telling if any differ
+func diffResources(client dryRunClient, fedResources []*unstructured.Unstructured, namespace string, out io.Writer) (bool, error) {
+	changed := false
+	inventory := util.NewInventory(fedResources, namespace)
+	for index, fedResource := range fedResources {
+		reference := inventory[index]
+		live, err := client.Get(*fedResource, reference.Namespace)
+		if apierrors.IsNotFound(err) {
+			live = nil
+		} else if err != nil {
+			return false, fmt.Errorf("Unable to get %s %s: %v", reference.Kind, reference.Name, err)
+		}
+		if live != nil {
+			// the revision annotation is owned by the controller , only a changed output makes it differ
+			if revision := util.TemplateRevision(live); revision != 0 {
+				if err := util.SetTemplateRevision(fedResource, revision); err != nil {
+					return false, err
+				}
+			}
+		}
+		merged, err := client.DryRunApply(*fedResource, reference.Namespace)
+		if err != nil {
+			return false, fmt.Errorf("Unable to dry run %s %s: %v", reference.Kind, reference.Name, err)
+		}
+		diff, err := objectDiff(reference, live, merged)
+		if err != nil {
+			return false, err
+		}
+		if diff != "" {
+			changed = true
+			fmt.Fprint(out, diff)
+		}
+	}
+	return changed, nil
+}
+
+// objectDiff returns the unified diff between the live and the merged object , empty when they match
+func objectDiff(reference util.ResourceReference, live *unstructured.Unstructured, merged *unstructured.Unstructured) (string, error) {
+	before, err := comparableYAML(live)
+	if err != nil {
+		return "", err
+	}
+	after, err := comparableYAML(merged)
+	if err != nil {
+		return "", err
+	}
+	path := fmt.Sprintf("%s/%s/%s", reference.Kind, reference.Namespace, reference.Name)
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before),
+		B:        difflib.SplitLines(after),
+		FromFile: "live/" + path,
+		ToFile:   "merged/" + path,
+		Context:  3,
+	})
+}
+
+// comparableYAML keeps the fields of the object the controller manages , dropping status and server set metadata
+func comparableYAML(obj *unstructured.Unstructured) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	metadata := map[string]interface{}{
+		"name": obj.GetName(),
+	}
+	if obj.GetNamespace() != "" {
+		metadata["namespace"] = obj.GetNamespace()
+	}
+	if len(obj.GetLabels()) > 0 {
+		metadata["labels"] = obj.GetLabels()
+	}
+	if len(obj.GetAnnotations()) > 0 {
+		metadata["annotations"] = obj.GetAnnotations()
+	}
+	comparable := map[string]interface{}{
+		"apiVersion": obj.GetAPIVersion(),
+		"kind":       obj.GetKind(),
+		"metadata":   metadata,
+	}
+	if spec, ok := obj.Object["spec"]; ok {
+		comparable["spec"] = spec
+	}
+	data, err := yaml.Marshal(comparable)
+	return string(data), err
+}
+
+// exitCode follows kubectl diff , 1 when there are changes and 2 on errors
+func exitCode(err error) int {
+	if err == errChanges {
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, err)
+	return 2
+}
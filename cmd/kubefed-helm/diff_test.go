@@ -0,0 +1,129 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"kubefed-application-controller/controllers/util"
+)
+
+// fakeDryRunClient serves a single live object , dry runs return the applied object as the only field
+// manager would leave it
+type fakeDryRunClient struct {
+	live *unstructured.Unstructured
+}
+
+func (client *fakeDryRunClient) Get(resourceObj unstructured.Unstructured, namespace string) (*unstructured.Unstructured, error) {
+	if client.live == nil {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: "types.kubefed.io", Resource: "federatedconfigmaps"}, resourceObj.GetName())
+	}
+	return client.live.DeepCopy(), nil
+}
+
+func (client *fakeDryRunClient) DryRunApply(resourceObj unstructured.Unstructured, namespace string) (*unstructured.Unstructured, error) {
+	merged := resourceObj.DeepCopy()
+	merged.SetNamespace(namespace)
+	return merged, nil
+}
+
+var _ = Describe("diff", func() {
+	reference := util.ResourceReference{APIVersion: "types.kubefed.io/v1beta1", Kind: "FederatedConfigMap", Namespace: "apps", Name: "web-config"}
+
+	federatedConfigMap := func(greeting string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "types.kubefed.io/v1beta1",
+			"kind":       "FederatedConfigMap",
+			"metadata": map[string]interface{}{
+				"name":            "web-config",
+				"namespace":       "apps",
+				"resourceVersion": "42",
+				"uid":             "1234",
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{"data": map[string]interface{}{"greeting": greeting}},
+			},
+			"status": map[string]interface{}{"observedGeneration": int64(3)},
+		}}
+		return obj
+	}
+
+	It("Should not report objects matching the live objects", func() {
+		live := federatedConfigMap("hello")
+		merged := federatedConfigMap("hello")
+		merged.SetResourceVersion("43")
+		unstructured.RemoveNestedField(merged.Object, "status")
+		diff, err := objectDiff(reference, live, merged)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff).To(BeEmpty())
+	})
+
+	It("Should report changed objects", func() {
+		diff, err := objectDiff(reference, federatedConfigMap("hello"), federatedConfigMap("hi"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff).To(ContainSubstring("--- live/FederatedConfigMap/apps/web-config"))
+		Expect(diff).To(ContainSubstring("-      greeting: hello"))
+		Expect(diff).To(ContainSubstring("+      greeting: hi"))
+	})
+
+	It("Should report missing objects", func() {
+		diff, err := objectDiff(reference, nil, federatedConfigMap("hello"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(diff).To(ContainSubstring("+kind: FederatedConfigMap"))
+	})
+
+	It("Should exit like kubectl diff", func() {
+		Expect(exitCode(errChanges)).To(Equal(1))
+		Expect(exitCode(fmt.Errorf("Unable to load kubeconfig"))).To(Equal(2))
+	})
+
+	It("Should not report objects the controller applied from the current revision", func() {
+		live := federatedConfigMap("hello")
+		Expect(util.SetTemplateRevision(live, 4)).To(Succeed())
+		rendered := federatedConfigMap("hello")
+		unstructured.RemoveNestedField(rendered.Object, "status")
+		var out bytes.Buffer
+		changed, err := diffResources(&fakeDryRunClient{live: live}, []*unstructured.Unstructured{rendered}, "apps", &out)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		Expect(out.String()).To(BeEmpty())
+	})
+
+	It("Should report changed output of the current revision", func() {
+		live := federatedConfigMap("hello")
+		Expect(util.SetTemplateRevision(live, 4)).To(Succeed())
+		var out bytes.Buffer
+		changed, err := diffResources(&fakeDryRunClient{live: live}, []*unstructured.Unstructured{federatedConfigMap("hi")}, "apps", &out)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(out.String()).To(ContainSubstring("+      greeting: hi"))
+		Expect(out.String()).ToNot(MatchRegexp(`(?m)^[-+].*` + util.RevisionAnnotation))
+	})
+
+	It("Should report objects missing from the host cluster", func() {
+		var out bytes.Buffer
+		changed, err := diffResources(&fakeDryRunClient{}, []*unstructured.Unstructured{federatedConfigMap("hello")}, "apps", &out)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed).To(BeTrue())
+	})
+})
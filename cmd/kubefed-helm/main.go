@@ -0,0 +1,57 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubefed-helm works with Application manifests outside of the controller
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: kubefed-helm <command> [flags]
+
+Commands:
+  render    Render an Application to the federated manifest the controller would apply
+  diff      Compare an Application with the federated objects on the KubeFed host cluster ,
+            exiting with 1 when they differ and 2 on errors
+  convert   Generate an Application from a helm release , optionally preparing KubeFed to adopt its objects
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "render":
+		err = runRender(os.Args[2:])
+	case "diff":
+		err = runDiff(os.Args[2:])
+	case "convert":
+		err = runConvert(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		os.Exit(exitCode(err))
+	}
+}
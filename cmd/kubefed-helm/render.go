@@ -0,0 +1,190 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/yaml"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	federationv2 "kubefed-application-controller/api/v2"
+	"kubefed-application-controller/controllers"
+	"kubefed-application-controller/controllers/util"
+)
+
+// valueFiles collects the repeated -values flags
+type valueFiles []string
+
+func (files *valueFiles) String() string {
+	return strings.Join(*files, ",")
+}
+
+func (files *valueFiles) Set(value string) error {
+	*files = append(*files, value)
+	return nil
+}
+
+func runRender(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	filename := flags.String("f", "", "The Application manifest to render.")
+	output := flags.String("o", "", "File to write the federated manifest to , stdout when empty.")
+	var values valueFiles
+	flags.Var(&values, "values", "Local values file overriding the values of the Application , can be repeated.")
+	revision := flags.Int("revision", 0, "Revision the templates are annotated with , the current revision of the Application status or 1 when 0.")
+	flags.Parse(args)
+	if *filename == "" {
+		return fmt.Errorf("The Application manifest is required , use -f")
+	}
+
+	application, err := readApplication(*filename)
+	if err != nil {
+		return err
+	}
+	rendered, err := renderApplication(application, values)
+	if err != nil {
+		return err
+	}
+	federatedManifest, err := prepareManifest(application, rendered, *revision)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = fmt.Print(*federatedManifest)
+		return err
+	}
+	return ioutil.WriteFile(*output, []byte(*federatedManifest), 0644)
+}
+
+// readApplication reads an Application manifest , defaulting and validating it for offline use
+func readApplication(filename string) (*federationv1.Application, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &typeMeta); err != nil {
+		return nil, fmt.Errorf("Unable to read Application %s: %v", filename, err)
+	}
+	application := &federationv1.Application{}
+	if typeMeta.APIVersion == federationv2.GroupVersion.String() {
+		// later versions are converted to v1 like the conversion webhook does
+		converted := &federationv2.Application{}
+		if err := yaml.UnmarshalStrict(data, converted); err != nil {
+			return nil, fmt.Errorf("Unable to read Application %s: %v", filename, err)
+		}
+		if err := converted.ConvertTo(application); err != nil {
+			return nil, err
+		}
+		application.TypeMeta = metav1.TypeMeta{APIVersion: federationv1.GroupVersion.String(), Kind: typeMeta.Kind}
+	} else if err := yaml.UnmarshalStrict(data, application); err != nil {
+		return nil, fmt.Errorf("Unable to read Application %s: %v", filename, err)
+	}
+	application.Default()
+	if err := validateOffline(application); err != nil {
+		return nil, fmt.Errorf("Invalid Application %s: %v", filename, err)
+	}
+	return application, nil
+}
+
+// validateOffline checks what the webhook checks without a cluster , the chart name is a local chart
+// path when no repository url is set , which the webhook does not accept
+func validateOffline(application *federationv1.Application) error {
+	if application.Spec.Type != federationv1.Helm {
+		return fmt.Errorf("Invalid application type %s .Only Helm is supported", application.Spec.Type)
+	}
+	chart := application.Spec.Template.Chart
+	if chart.Name == "" {
+		return fmt.Errorf("Invalid/empty chart name")
+	}
+	if chart.RepositoryRef != "" {
+		return fmt.Errorf("HelmRepository %s cannot be resolved offline , set repoUrl or a local chart path instead", chart.RepositoryRef)
+	}
+	if _, err := chart.ChartValues(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// renderApplication renders the chart without a cluster connection and converts it to federated resources
+func renderApplication(application *federationv1.Application, valueFiles []string) (*string, error) {
+	chart := application.Spec.Template.Chart
+	chartValues, err := chart.ChartValues()
+	if err != nil {
+		return nil, err
+	}
+	// rendering is client only , the kubeconfig is never used
+	helmClient, err := util.NewHelmClient(&rest.Config{})
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := helmClient.Template(application.ReleaseName(), chart.Name, chart.Repo, util.GlobalOptions{
+		Namespace:  chart.Namespace,
+		Version:    chart.Version,
+		Values:     chartValues,
+		ValueFiles: valueFiles,
+		// member clusters are not known offline , only explicit capabilities apply
+		Capabilities: util.ExplicitCapabilities(application.Spec.KubeVersion, application.Spec.APIVersions),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to generate a helm template from chart %s: %v", chart.Name, err)
+	}
+	converter, err := util.NewFederatedResourceConverter(manifest)
+	if err != nil {
+		return nil, err
+	}
+	federatedManifest, err := converter.GenerateFederatedManifest(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to generate a federated manifest: %v", err)
+	}
+	return federatedManifest, nil
+}
+
+// prepareManifest labels and annotates the federated resources like the controller does when applying
+// the revision
+func prepareManifest(application *federationv1.Application, federatedManifest *string, revision int) (*string, error) {
+	if revision == 0 {
+		revision = application.Status.CurrentRevision
+	}
+	if revision == 0 {
+		revision = 1
+	}
+	fedResources, err := util.ParseManifest(federatedManifest)
+	if err != nil {
+		return nil, err
+	}
+	for _, fedResource := range fedResources {
+		if err := util.SetTemplateRevision(fedResource, revision); err != nil {
+			return nil, err
+		}
+	}
+	controllers.PrepareFederatedResources(application, fedResources)
+	prepared := ""
+	for _, fedResource := range fedResources {
+		data, err := yaml.Marshal(fedResource.Object)
+		if err != nil {
+			return nil, err
+		}
+		prepared += "---\n" + string(data)
+	}
+	return &prepared, nil
+}
@@ -0,0 +1,158 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+const configMapTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-config
+data:
+  greeting: {{ .Values.greeting }}
+`
+
+var _ = Describe("render", func() {
+	var dir string
+
+	writeApplication := func(manifest string) string {
+		filename := filepath.Join(dir, "application.yaml")
+		Expect(ioutil.WriteFile(filename, []byte(manifest), 0644)).To(Succeed())
+		return filename
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "render")
+		Expect(err).ToNot(HaveOccurred())
+		web := &chart.Chart{
+			Metadata:  &chart.Metadata{APIVersion: "v2", Name: "web", Version: "0.1.0"},
+			Values:    map[string]interface{}{"greeting": "hello"},
+			Templates: []*chart.File{{Name: "templates/configmap.yaml", Data: []byte(configMapTemplate)}},
+		}
+		Expect(chartutil.SaveDir(web, dir)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Should render an Application of a local chart to federated objects", func() {
+		filename := writeApplication(`apiVersion: federation.kubefed.fulliautomatix.site/v1
+kind: Application
+metadata:
+  name: web
+  namespace: team-web
+spec:
+  type: Helm
+  template:
+    chart:
+      name: ` + filepath.Join(dir, "web") + `
+      namespace: apps
+      values:
+        greeting: hi
+`)
+		application, err := readApplication(filename)
+		Expect(err).ToNot(HaveOccurred())
+		manifest, err := renderApplication(application, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*manifest).To(ContainSubstring("kind: FederatedConfigMap"))
+		Expect(*manifest).To(ContainSubstring("name: web-config"))
+		Expect(*manifest).To(ContainSubstring("greeting: hi"))
+
+		By("Labeling and annotating the federated objects like the controller")
+		prepared, err := prepareManifest(application, manifest, 0)
+		Expect(err).ToNot(HaveOccurred())
+		fedResources, err := util.ParseManifest(prepared)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fedResources).To(HaveLen(1))
+		Expect(fedResources[0].GetLabels()).To(HaveKeyWithValue(federationv1.ApplicationNameLabel, "web"))
+		Expect(fedResources[0].GetLabels()).To(HaveKeyWithValue(federationv1.ApplicationNamespaceLabel, "team-web"))
+		Expect(util.TemplateRevision(fedResources[0])).To(Equal(1))
+
+		application.Status.CurrentRevision = 3
+		prepared, err = prepareManifest(application, manifest, 0)
+		Expect(err).ToNot(HaveOccurred())
+		fedResources, err = util.ParseManifest(prepared)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(util.TemplateRevision(fedResources[0])).To(Equal(3))
+	})
+
+	It("Should override the values with local values files", func() {
+		filename := writeApplication(`apiVersion: federation.kubefed.fulliautomatix.site/v1
+kind: Application
+metadata:
+  name: web
+spec:
+  type: Helm
+  template:
+    chart:
+      name: ` + filepath.Join(dir, "web") + `
+      namespace: apps
+`)
+		valuesFile := filepath.Join(dir, "values.yaml")
+		Expect(ioutil.WriteFile(valuesFile, []byte("greeting: howdy\n"), 0644)).To(Succeed())
+		application, err := readApplication(filename)
+		Expect(err).ToNot(HaveOccurred())
+		manifest, err := renderApplication(application, []string{valuesFile})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*manifest).To(ContainSubstring("greeting: howdy"))
+	})
+
+	It("Should refuse Applications referencing a HelmRepository", func() {
+		filename := writeApplication(`apiVersion: federation.kubefed.fulliautomatix.site/v1
+kind: Application
+metadata:
+  name: web
+spec:
+  type: Helm
+  template:
+    chart:
+      name: web
+      namespace: apps
+      repositoryRef: charts
+`)
+		_, err := readApplication(filename)
+		Expect(err).To(MatchError(ContainSubstring("cannot be resolved offline")))
+	})
+
+	It("Should refuse Applications without a chart", func() {
+		filename := writeApplication(`apiVersion: federation.kubefed.fulliautomatix.site/v1
+kind: Application
+metadata:
+  name: web
+spec:
+  type: Helm
+  template:
+    chart:
+      namespace: apps
+`)
+		_, err := readApplication(filename)
+		Expect(err).To(MatchError(ContainSubstring("empty chart name")))
+	})
+})
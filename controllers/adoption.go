@@ -0,0 +1,161 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+// ownershipClashError is returned when existing federated objects the application may not adopt
+// block its deployment , nothing is applied until the clashes are resolved
+type ownershipClashError struct {
+	clashes []federationv1.OwnershipClash
+}
+
+func (err *ownershipClashError) Error() string {
+	return fmt.Sprintf("Refusing to modify %d existing federated objects not owned by the application , change the adoption policy to take them over", len(err.clashes))
+}
+
+// checkOwnership fails with an ownershipClashError when existing federated objects of the revision
+// may not be taken over following the adoption policy , the clashes are reported in the status
+func (r *ApplicationReconciler) checkOwnership(application *federationv1.Application, dynamicClient util.DynamicClient, fedResources []*unstructured.Unstructured, namespace string, revision int) error {
+	application.Status.Clashes = nil
+	inventory := util.NewInventory(fedResources, namespace)
+	created, err := r.createdResources(application, revision)
+	if err != nil {
+		return err
+	}
+	self := application.Namespace + "/" + application.Name
+	for index, fedResource := range fedResources {
+		live, err := dynamicClient.Get(*fedResource, inventory[index].Namespace)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		owner := resourceOwner(live)
+		if owner == self {
+			continue
+		}
+		// objects the application created before they were labeled with their owner , other unlabeled
+		// objects are only taken over following the adoption policy
+		if owner == "" && created[inventory[index]] {
+			continue
+		}
+		adoptable, err := r.canAdopt(application.Spec.AdoptionPolicy, owner)
+		if err != nil {
+			return err
+		}
+		if !adoptable {
+			application.Status.Clashes = append(application.Status.Clashes, federationv1.OwnershipClash{
+				ResourceReference: federationv1.ResourceReference(inventory[index]),
+				Owner:             owner,
+			})
+		}
+	}
+	if len(application.Status.Clashes) > 0 {
+		var clashes []string
+		for _, clash := range application.Status.Clashes {
+			clashes = append(clashes, fmt.Sprintf("%s %s", clash.Kind, clash.Name))
+		}
+		application.Status.SetCondition(federationv1.ApplicationCondition{
+			Type:    federationv1.ResourcesOwned,
+			Status:  corev1.ConditionFalse,
+			Reason:  "OwnershipClash",
+			Message: fmt.Sprintf("Existing federated objects may not be adopted: %s", strings.Join(clashes, " , ")),
+		})
+		return &ownershipClashError{clashes: application.Status.Clashes}
+	}
+	application.Status.SetCondition(federationv1.ApplicationCondition{
+		Type:   federationv1.ResourcesOwned,
+		Status: corev1.ConditionTrue,
+		Reason: "ResourcesOwned",
+	})
+	return nil
+}
+
+// createdResources lists the federated objects of every other revision , objects created before they
+// were labeled with their owner are still owned by the application
+func (r *ApplicationReconciler) createdResources(application *federationv1.Application, revision int) (map[util.ResourceReference]bool, error) {
+	revisions, err := r.revisions(application).List()
+	if err != nil {
+		return nil, err
+	}
+	created := map[util.ResourceReference]bool{}
+	for _, other := range revisions {
+		if other.Number == revision {
+			continue
+		}
+		for _, reference := range other.Inventory {
+			created[reference] = true
+		}
+	}
+	return created, nil
+}
+
+// resourceOwner is the namespace and name of the application owning a federated object
+func resourceOwner(live *unstructured.Unstructured) string {
+	labels := live.GetLabels()
+	name, ok := labels[federationv1.ApplicationNameLabel]
+	if !ok {
+		return ""
+	}
+	return labels[federationv1.ApplicationNamespaceLabel] + "/" + name
+}
+
+// canAdopt tells if the adoption policy allows taking over an object of the owner , objects of
+// another existing application are never taken over
+func (r *ApplicationReconciler) canAdopt(policy federationv1.AdoptionPolicy, owner string) (bool, error) {
+	switch policy {
+	case federationv1.AdoptAlways:
+		if owner == "" {
+			return true, nil
+		}
+		exists, err := r.applicationExists(owner)
+		return !exists, err
+	case federationv1.AdoptIfUnowned:
+		return owner == "", nil
+	}
+	return false, nil
+}
+
+// applicationExists tells if the application or cluster application owning an object still exists
+func (r *ApplicationReconciler) applicationExists(owner string) (bool, error) {
+	parts := strings.SplitN(owner, "/", 2)
+	key := types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	var err error
+	if key.Namespace == "" {
+		err = r.Get(context.TODO(), key, &federationv1.ClusterApplication{})
+	} else {
+		err = r.Get(context.TODO(), key, &federationv1.Application{})
+	}
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -19,7 +19,11 @@ package controllers
 import (
 	"context"
 	"fmt"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/rest"
 	"kubefed-application-controller/controllers/util"
 	"time"
@@ -28,23 +32,48 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	federationv1 "kubefed-application-controller/api/v1"
 )
 
 const applicationFinalizer = "applicatio.finalizers.federation.kubefed.fulliautomatix.site"
 
+// healthRequeueInterval is how often an application with unhealthy workloads is checked again
+const healthRequeueInterval = 30 * time.Second
+
 // ApplicationReconciler reconciles a Application object
 type ApplicationReconciler struct {
 	client.Client
-	Config *rest.Config
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Config         *rest.Config
+	Log            logr.Logger
+	Scheme         *runtime.Scheme
+	HealthChecker  util.HealthChecker
+	Revisions      util.RevisionStore
+	MemberClusters util.MemberClusterClient
+	// Member clusters the cluster selectors of the federated objects are resolved against
+	Clusters util.ClusterSelector
+	// Discovered capabilities of the member clusters the chart is rendered against
+	ClusterCapabilities *util.CapabilitiesCache
+	// Federated types watched for changes to the generated objects
+	FederatedKinds []schema.GroupVersionKind
+	// Applications matching the selector are suspended regardless of their spec
+	SuspendSelector labels.Selector
+	// Reject applications not naming a service account to impersonate
+	RequireServiceAccount bool
+	// Namespace holding the revisions and impersonated service accounts of cluster applications
+	ClusterApplicationNamespace string
 }
 
 // +kubebuilder:rbac:groups=federation.kubefed.fulliautomatix.site,resources=applications,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=federation.kubefed.fulliautomatix.site,resources=applications/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=types.kubefed.io,resources=federateddeployments;federatedservices;federatedconfigmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core.kubefed.io,resources=kubefedclusters,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=impersonate
+// +kubebuilder:rbac:groups=federation.kubefed.fulliautomatix.site,resources=applicationpolicies,verbs=get;list;watch
 
 func (r *ApplicationReconciler) Reconcile(req ctrl.Request) (result ctrl.Result, reterr error) {
 	context := context.Background()
@@ -59,25 +88,48 @@ func (r *ApplicationReconciler) Reconcile(req ctrl.Request) (result ctrl.Result,
 	}
 
 	defer func() {
-		err := r.Client.Update(context, &application)
+		status := application.Status
+		err := r.updateApplication(&application, func() { application.Status = status })
 		if err != nil {
 			log.Error(err, "Unable to update status ")
-			if reterr != nil {
+			if reterr == nil {
 				reterr = err
 			}
 		}
 	}()
+	return r.reconcileApplication(&application, log)
+}
+
+// updateApplication persists the finalizers with an update of the object and the status through the status
+// subresource , restoreStatus puts back the status the update replaced with the stored one
+func (r *ApplicationReconciler) updateApplication(object runtime.Object, restoreStatus func()) error {
+	if err := r.Client.Update(context.Background(), object); err != nil {
+		return err
+	}
+	restoreStatus()
+	// removing the last finalizer deletes the object
+	return client.IgnoreNotFound(r.Client.Status().Update(context.Background(), object))
+}
 
-	retVal, err := r.handleFinalizers(&application)
+// reconcileApplication renders and deploys the application , recording the outcome in its status
+// and finalizers which the caller persists
+func (r *ApplicationReconciler) reconcileApplication(application *federationv1.Application, log logr.Logger) (ctrl.Result, error) {
+	retVal, err := r.handleFinalizers(application, log)
 	// if there is an error or the finalizers have been added/removed from our Application, then return
 	if err != nil || retVal {
 		return ctrl.Result{}, err
 	}
 
+	if r.suspended(*application) {
+		log.Info("Application is suspended , skipping deployment")
+		application.Status.State = federationv1.Suspended
+		return ctrl.Result{}, nil
+	}
+
 	application.Status.State = federationv1.Deploying
 
 	// First validate the input application
-	err = r.validateApplication(application)
+	err = r.validateApplication(*application)
 	if err != nil {
 		log.Error(err, "Unable to validate application")
 		application.Status.State = federationv1.Errored
@@ -85,21 +137,208 @@ func (r *ApplicationReconciler) Reconcile(req ctrl.Request) (result ctrl.Result,
 		// Skip if not found
 		return ctrl.Result{}, err
 	}
-	err = r.deployApplication(application, log)
+	repository, err := federationv1.ResolveChartRepository(r.Client, application.Namespace, application.Spec.Template.Chart)
 	if err != nil {
-		log.Error(err, "Unable to deploy application")
+		log.Error(err, "Unable to resolve chart repository")
 		application.Status.State = federationv1.Errored
+		return ctrl.Result{}, err
+	}
+	// policies and revisions see the url of a referenced repository
+	resolved := application.WithChartRepository(repository)
+	application.Status.PolicyViolations = nil
+	if err := r.checkPolicies(resolved); err != nil {
+		return r.rejectPolicyViolation(application, err, log)
+	}
+	if r.RequireServiceAccount && application.Spec.ServiceAccountName == "" {
+		log.Info("Application does not name a service account to impersonate")
+		application.Status.State = federationv1.Rejected
+		return ctrl.Result{}, nil
+	}
+	inputs, err := revisionInputs(*resolved)
+	if err != nil {
+		log.Error(err, "Unable to read application inputs")
+		application.Status.State = federationv1.Errored
+		return ctrl.Result{}, err
+	}
+	if application.Spec.DryRun || application.ObjectMeta.Annotations[federationv1.PreviewAnnotation] == "true" {
+		return r.previewApplication(application, inputs, log)
+	}
+	application.Status.Preview = nil
 
-		// Skip if not found
+	defer r.updateHistory(application, log)
+
+	if application.Spec.RollbackTo != nil {
+		application.Status.Rollback = nil
+		return r.reapplyRevision(application, *application.Spec.RollbackTo, federationv1.Deployed, log)
+	}
+	if rollback := application.Status.Rollback; rollback != nil {
+		if rollback.FailedInputsHash == inputs.Hash() {
+			// keep the last good revision until the failed spec is changed
+			return r.reapplyRevision(application, rollback.Revision, federationv1.RolledBack, log)
+		}
+		application.Status.Rollback = nil
+	}
+
+	revision, err := r.deployApplication(application, inputs, log)
+	if _, ok := err.(*policyViolationError); ok {
+		return r.rejectPolicyViolation(application, err, log)
+	}
+	if _, ok := err.(*util.ValuesError); ok {
+		log.Info("Chart values do not match the values schema", "errors", err.Error())
+		application.Status.State = federationv1.Rejected
+		return ctrl.Result{}, nil
+	}
+	if _, ok := err.(*recordRevisionError); ok {
+		// e.g. a revision with the same number created concurrently , nothing was applied
+		log.Error(err, "Unable to record revision")
 		return ctrl.Result{}, err
 	}
+	if _, ok := err.(*ownershipClashError); ok {
+		// reconciled again once the spec changes , e.g. the adoption policy
+		log.Info("Existing federated objects may not be adopted", "clashes", len(application.Status.Clashes))
+		application.Status.State = federationv1.Rejected
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		log.Error(err, "Unable to deploy application")
+		return r.handleDeploymentFailure(application, inputs, revision, err, log)
+	}
 	application.Status.State = federationv1.Deployed
+	application.Status.FailureCount = 0
+	application.Status.CurrentRevision = revision.Number
 
 	application.Status.DeployedTimestamp = &metav1.Time{Time: time.Now()}
+
+	resources, err := util.ParseManifest(&revision.Manifest)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	r.updateHealth(application, resources, revision, log)
+	r.updatePropagation(application, revision)
+	advanced, err := r.advanceRollout(application, log)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if advanced {
+		return ctrl.Result{Requeue: true}, nil
+	}
+	if err := r.recordRevisionHealth(application, inputs, revision, log); err != nil {
+		return ctrl.Result{}, err
+	}
+	if application.Status.Rollout != nil && application.Status.Rollout.Paused {
+		return ctrl.Result{}, nil
+	}
+	if rolloutInProgress(application, revision) || (application.Status.Health != "" && application.Status.Health != federationv1.Healthy) {
+		return ctrl.Result{RequeueAfter: healthRequeueInterval}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
-func (r *ApplicationReconciler) handleFinalizers(application *federationv1.Application) (bool, error) {
+// updateHealth records the health of the propagated workloads per member cluster the revision is placed on
+func (r *ApplicationReconciler) updateHealth(application *federationv1.Application, resources []*unstructured.Unstructured, revision *util.Revision, log logr.Logger) {
+	if r.HealthChecker == nil {
+		return
+	}
+	placement, err := r.placementClusters(revision)
+	if err != nil {
+		log.Error(err, "Unable to resolve the placement of the federated objects")
+		application.Status.Health = federationv1.Unknown
+		application.Status.Clusters = nil
+		return
+	}
+	clusterHealths, err := r.HealthChecker.CheckHealth(resources, application.Spec.Template.Chart.Namespace, expectedRevisions(application, revision, placement))
+	if err != nil {
+		log.Error(err, "Unable to check health of member clusters")
+		application.Status.Health = federationv1.Unknown
+		application.Status.Clusters = nil
+		return
+	}
+	overall := util.Healthy
+	application.Status.Clusters = nil
+	for _, clusterHealth := range clusterHealths {
+		if clusterHealth.Health > overall {
+			overall = clusterHealth.Health
+		}
+		application.Status.Clusters = append(application.Status.Clusters, federationv1.ClusterStatus{
+			Name:    clusterHealth.Cluster,
+			Health:  federationv1.HealthState(clusterHealth.Health.String()),
+			Message: clusterHealth.Message,
+		})
+	}
+	application.Status.Health = federationv1.HealthState(overall.String())
+}
+
+// updatePropagation records whether kubefed propagated every federated object of the revision
+func (r *ApplicationReconciler) updatePropagation(application *federationv1.Application, revision *util.Revision) {
+	condition := federationv1.ApplicationCondition{
+		Type:   federationv1.Propagated,
+		Status: corev1.ConditionTrue,
+		Reason: "Propagated",
+	}
+	dynamicClient, err := r.newDeployer(application)
+	if err != nil {
+		application.Status.SetCondition(federationv1.ApplicationCondition{
+			Type:    federationv1.Propagated,
+			Status:  corev1.ConditionUnknown,
+			Reason:  "PropagationUnknown",
+			Message: err.Error(),
+		})
+		return
+	}
+	for _, reference := range revision.Inventory {
+		object := unstructured.Unstructured{}
+		object.SetAPIVersion(reference.APIVersion)
+		object.SetKind(reference.Kind)
+		object.SetName(reference.Name)
+		live, err := dynamicClient.Get(object, reference.Namespace)
+		if err != nil {
+			condition.Status, condition.Reason = corev1.ConditionUnknown, "PropagationUnknown"
+			condition.Message = fmt.Sprintf("%s %s: %v", reference.Kind, reference.Name, err)
+			break
+		}
+		if propagated, message := util.FederatedPropagation(live); !propagated {
+			condition.Status, condition.Reason = corev1.ConditionFalse, "Propagating"
+			condition.Message = fmt.Sprintf("%s %s: %s", reference.Kind, reference.Name, message)
+			break
+		}
+	}
+	application.Status.SetCondition(condition)
+}
+
+// propagated tells if the federated objects of the current revision reached the member clusters
+func propagated(application *federationv1.Application) bool {
+	condition := application.Status.GetCondition(federationv1.Propagated)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
+
+// currentPlacement are the member clusters the current revision is placed on , the chart is rendered
+// before the placement of the new output is known , nil before the first revision
+func (r *ApplicationReconciler) currentPlacement(application *federationv1.Application) ([]string, error) {
+	if application.Status.CurrentRevision == 0 {
+		return nil, nil
+	}
+	revisions, err := r.revisions(application).List()
+	if err != nil {
+		return nil, err
+	}
+	for _, revision := range revisions {
+		if revision.Number == application.Status.CurrentRevision {
+			return r.placementClusters(revision)
+		}
+	}
+	return nil, nil
+}
+
+// placementClusters are the member clusters the federated objects of the revision are placed on
+func (r *ApplicationReconciler) placementClusters(revision *util.Revision) ([]string, error) {
+	fedResources, err := util.ParseManifest(&revision.FederatedManifest)
+	if err != nil {
+		return nil, err
+	}
+	return util.PlacementClusters(fedResources, r.Clusters)
+}
+
+func (r *ApplicationReconciler) handleFinalizers(application *federationv1.Application, log logr.Logger) (bool, error) {
 	if application.ObjectMeta.DeletionTimestamp.IsZero() {
 		// Register our finalizer so that the hook is called before the application is deleted
 		if !containsString(application.ObjectMeta.Finalizers, applicationFinalizer) {
@@ -108,8 +347,9 @@ func (r *ApplicationReconciler) handleFinalizers(application *federationv1.Appli
 		}
 	} else {
 		if containsString(application.ObjectMeta.Finalizers, applicationFinalizer) {
-
-			// TODO: Need to clean up all the federated resources
+			if err := r.deleteFederatedResources(application, log); err != nil {
+				return true, err
+			}
 			application.ObjectMeta.Finalizers = removeString(application.ObjectMeta.Finalizers, applicationFinalizer)
 			return true, nil
 
@@ -119,6 +359,15 @@ func (r *ApplicationReconciler) handleFinalizers(application *federationv1.Appli
 	}
 	return false, nil
 }
+
+// suspended tells if reconciliation of the application is suspended by its spec or by the manager
+func (r *ApplicationReconciler) suspended(application federationv1.Application) bool {
+	if application.Spec.Suspend {
+		return true
+	}
+	return r.SuspendSelector != nil && !r.SuspendSelector.Empty() && r.SuspendSelector.Matches(labels.Set(application.ObjectMeta.Labels))
+}
+
 func (r *ApplicationReconciler) validateApplication(application federationv1.Application) error {
 	if application.Spec.Type == "" || application.Spec.Type != federationv1.Helm {
 		return fmt.Errorf("Invalid application type %s .Only Helm is supported", application.Spec.Type)
@@ -132,37 +381,200 @@ func (r *ApplicationReconciler) validateApplication(application federationv1.App
 	return nil
 }
 
-func (r *ApplicationReconciler) deployApplication(application federationv1.Application, log logr.Logger) error {
-	chartName := application.Spec.Template.Chart.Name
-	chartRepo := application.Spec.Template.Chart.Repo
+// revisionInputs collects everything the rendered output of the application depends on
+func revisionInputs(application federationv1.Application) (util.RevisionInputs, error) {
+	chart := application.Spec.Template.Chart
+	inputs := util.RevisionInputs{
+		ReleaseName: application.ReleaseName(),
+		Chart:       chart.Name,
+		Repo:        chart.Repo,
+		Version:     chart.Version,
+		Namespace:   chart.Namespace,
+		KubeVersion: application.Spec.KubeVersion,
+		APIVersions: application.Spec.APIVersions,
+	}
+	values, err := chart.ChartValues()
+	if err != nil {
+		return inputs, err
+	}
+	inputs.Values = values
+	return inputs, nil
+}
+
+// renderApplication renders the chart and converts the output to federated resources
+func (r *ApplicationReconciler) renderApplication(application *federationv1.Application, inputs util.RevisionInputs, log logr.Logger) (*util.RenderedChart, *string, error) {
 	helmClient, err := util.NewHelmClient(r.Config)
 	if err != nil {
-		return fmt.Errorf("Unable to create helm client")
+		return nil, nil, fmt.Errorf("Unable to create helm client")
+	}
+	capabilities, err := r.renderCapabilities(application, inputs)
+	if err != nil {
+		return nil, nil, err
+	}
+	repository, err := federationv1.ResolveChartRepository(r.Client, application.Namespace, application.Spec.Template.Chart)
+	if err != nil {
+		return nil, nil, err
+	}
+	rendered, err := helmClient.Render(inputs.ReleaseName, inputs.Chart, inputs.Repo, util.GlobalOptions{
+		Namespace:    inputs.Namespace,
+		Version:      inputs.Version,
+		Values:       inputs.Values,
+		Capabilities: capabilities,
+		Credentials:  repositoryCredentials(repository),
+	})
+	if valuesErr, ok := err.(*util.ValuesError); ok {
+		setValuesCondition(application, valuesErr)
+		return nil, nil, valuesErr
 	}
-	template, err := helmClient.Template(application.ObjectMeta.Name, chartName, chartRepo, util.GlobalOptions{Namespace: application.Spec.Template.Chart.Namespace})
 	if err != nil {
 		log.Error(err, "Unable to create template for application")
-		return fmt.Errorf("Unable to generate a helm template from chart %s", chartName)
+		return nil, nil, fmt.Errorf("Unable to generate a helm template from chart %s", inputs.Chart)
+	}
+	setValuesCondition(application, nil)
+	template := &rendered.Manifest
+	resources, err := util.ParseManifest(template)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to parse the rendered manifest: %v", err)
+	}
+	if err := capabilities.CheckResources(resources); err != nil {
+		return nil, nil, err
 	}
 
 	kubefedConverter, err := util.NewFederatedResourceConverter(template)
 	if err != nil {
-		return fmt.Errorf("Unable to create a kubefedctl converter")
+		return nil, nil, fmt.Errorf("Unable to create a kubefedctl converter")
 	}
-	fedResources, err := kubefedConverter.GenerateFederatedUnstructuredList(template)
+	federatedManifest, err := kubefedConverter.GenerateFederatedManifest(template)
 	if err != nil {
-		return fmt.Errorf("Unable to  generate a federated manifest")
+		return nil, nil, fmt.Errorf("Unable to  generate a federated manifest")
+	}
+	return rendered, federatedManifest, nil
+}
+
+// renderCapabilities are the capabilities given in the spec , or those of the member clusters the
+// application is placed on
+func (r *ApplicationReconciler) renderCapabilities(application *federationv1.Application, inputs util.RevisionInputs) (*util.Capabilities, error) {
+	if capabilities := util.ExplicitCapabilities(inputs.KubeVersion, inputs.APIVersions); capabilities != nil {
+		return capabilities, nil
+	}
+	if r.ClusterCapabilities == nil {
+		return nil, nil
 	}
-	dynamicClient, err := util.NewServerSideDeployer(r.Config)
+	clusters, err := r.currentPlacement(application)
 	if err != nil {
-		return fmt.Errorf("Unable to create a dynamic client")
+		return nil, err
+	}
+	capabilities, err := r.ClusterCapabilities.MemberClusterCapabilities(clusters)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to discover the capabilities of the member clusters: %v", err)
+	}
+	return capabilities, nil
+}
+
+// deployApplication renders and applies the application , recording the result as a revision
+func (r *ApplicationReconciler) deployApplication(application *federationv1.Application, inputs util.RevisionInputs, log logr.Logger) (*util.Revision, error) {
+	rendered, federatedManifest, err := r.renderApplication(application, inputs, log)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := r.recordRevision(*application, inputs, rendered, *federatedManifest)
+	if err != nil {
+		return nil, &recordRevisionError{err: err}
+	}
+	fedResources, err := r.rolloutResources(application, revision)
+	if err != nil {
+		return revision, fmt.Errorf("Unable to prepare rollout: %v", err)
+	}
+	PrepareFederatedResources(application, fedResources)
+
+	dynamicClient, err := r.newDeployer(application)
+	if err != nil {
+		return revision, fmt.Errorf("Unable to create a dynamic client")
+	}
+	// drift is only meaningful once the revision has been applied completely
+	if revision.Number == application.Status.CurrentRevision && !rolloutInProgress(application, revision) {
+		correct, err := r.detectDrift(application, dynamicClient, fedResources, inputs.Namespace, log)
+		if err != nil {
+			return revision, fmt.Errorf("Unable to detect drift: %v", err)
+		}
+		if !correct {
+			return revision, nil
+		}
+	} else {
+		application.Status.Drift = nil
+	}
+	if err := r.applyFederatedResources(application, dynamicClient, fedResources, inputs.Namespace, revision.Number); err != nil {
+		return revision, err
+	}
+	return revision, nil
+}
+
+// newDeployer creates the server side deployer following the apply policy of the application ,
+// impersonating its service account
+func (r *ApplicationReconciler) newDeployer(application *federationv1.Application) (*util.ServerSideDeployer, error) {
+	config := r.Config
+	if application.Spec.ServiceAccountName != "" {
+		config = util.ImpersonatedConfig(r.Config, r.applicationNamespace(application), application.Spec.ServiceAccountName)
+	}
+	deployer, err := util.NewServerSideDeployer(config)
+	if err != nil {
+		return nil, err
+	}
+	deployer.Force = application.Spec.ApplyPolicy != nil && application.Spec.ApplyPolicy.Force
+	return deployer, nil
+}
+
+// applyRevision server side applies every federated resource of the revision
+func (r *ApplicationReconciler) applyRevision(application *federationv1.Application, revision *util.Revision) error {
+	fedResources, err := util.ParseManifest(&revision.FederatedManifest)
+	if err != nil {
+		return fmt.Errorf("Unable to parse the federated manifest")
+	}
+	for _, fedResource := range fedResources {
+		if err := util.SetTemplateRevision(fedResource, revision.Number); err != nil {
+			return err
+		}
 	}
-	for _, eachFederatedResource := range fedResources {
-		err = dynamicClient.Apply(*eachFederatedResource, application.Spec.Template.Chart.Namespace)
+	PrepareFederatedResources(application, fedResources)
+	dynamicClient, err := r.newDeployer(application)
+	if err != nil {
+		return fmt.Errorf("Unable to create a dynamic client")
 	}
+	return r.applyFederatedResources(application, dynamicClient, fedResources, revision.Inputs.Namespace, revision.Number)
+}
 
-	//TODO add support for specific chart versions.
-	return err
+// applyFederatedResources applies the federated resources , collecting the fields owned by other managers in the status
+func (r *ApplicationReconciler) applyFederatedResources(application *federationv1.Application, dynamicClient util.DynamicClient, fedResources []*unstructured.Unstructured, namespace string, revision int) error {
+	application.Status.Conflicts = nil
+	if err := r.enforcePolicies(application, fedResources); err != nil {
+		return err
+	}
+	if err := r.checkOwnership(application, dynamicClient, fedResources, namespace, revision); err != nil {
+		if _, ok := err.(*ownershipClashError); ok {
+			return err
+		}
+		return fmt.Errorf("Unable to check ownership of federated objects: %v", err)
+	}
+	inventory := util.NewInventory(fedResources, namespace)
+	for index, eachFederatedResource := range fedResources {
+		err := dynamicClient.Apply(*eachFederatedResource, namespace)
+		conflicts := util.ParseConflicts(err)
+		if err != nil && conflicts == nil {
+			return err
+		}
+		for _, conflict := range conflicts {
+			application.Status.Conflicts = append(application.Status.Conflicts, federationv1.FieldConflict{
+				ResourceReference: federationv1.ResourceReference(inventory[index]),
+				Field:             conflict.Field,
+				Manager:           conflict.Manager,
+			})
+		}
+	}
+	if len(application.Status.Conflicts) > 0 {
+		return fmt.Errorf("Apply conflicts with %d fields owned by other field managers , force the apply or release the fields", len(application.Status.Conflicts))
+	}
+	return r.pruneFederatedResources(application, dynamicClient, revision, inventory)
 }
 
 func containsString(slice []string, s string) bool {
@@ -184,7 +596,30 @@ func removeString(slice []string, s string) (result []string) {
 	return
 }
 func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&federationv1.Application{}).
-		Complete(r)
+		Build(r)
+	if err != nil {
+		return err
+	}
+	for _, gvk := range r.FederatedKinds {
+		federatedType := &unstructured.Unstructured{}
+		federatedType.SetGroupVersionKind(gvk)
+		// only spec and metadata changes of the generated objects matter , not the status written by kubefed
+		err = c.Watch(&source.Kind{Type: federatedType}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(ownerApplicationRequests),
+		}, predicate.Funcs{UpdateFunc: federatedObjectChanged})
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Watch(&source.Kind{Type: &federationv1.ApplicationPolicy{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(r.policyApplicationRequests),
+	})
+	if err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &federationv1.HelmRepository{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(r.repositoryApplicationRequests),
+	}, predicate.Funcs{UpdateFunc: repositoryIndexChanged})
 }
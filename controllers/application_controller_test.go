@@ -2,11 +2,20 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	appv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
 	"time"
 )
 
@@ -58,4 +67,660 @@ var _ = Describe("application controller", func() {
 		})
 	})
 
+	Context("When an application is suspended ", func() {
+		It("Should not deploy it until it is resumed ", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "suspend-test", Namespace: AppNameSpace}
+			application := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+					ReleaseName: "suspend-test",
+					Suspend:     true,
+				},
+			}
+			Expect(k8sClient.Create(ctx, application)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, application)).Should(Succeed())
+			}()
+			Eventually(func() appv1.ApplicationDeploymentState {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return ""
+				}
+				return application.Status.State
+			}, timeout, interval).Should(Equal(appv1.Suspended))
+			deployment := &unstructured.Unstructured{}
+			deployment.SetAPIVersion("types.kubefed.io/v1beta1")
+			deployment.SetKind("FederatedDeployment")
+			deploymentKey := types.NamespacedName{Namespace: "kubefed-poc", Name: "suspend-test-nginx"}
+			Consistently(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, deploymentKey, deployment))
+			}, duration, interval).Should(BeTrue())
+			Expect(application.Status.CurrentRevision).To(BeZero())
+
+			By("Resuming the application")
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return err
+				}
+				application.Spec.Suspend = false
+				return k8sClient.Update(ctx, application)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() appv1.ApplicationDeploymentState {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return ""
+				}
+				return application.Status.State
+			}, timeout, interval).Should(Equal(appv1.Deployed))
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).Should(Succeed())
+		})
+	})
+
+	Context("When an application violates its application policies ", func() {
+		It("Should reject the application and still delete it ", func() {
+			ctx := context.Background()
+			policy := &appv1.ApplicationPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "policy-test"},
+				Spec: appv1.ApplicationPolicySpec{
+					SourceNamespaces: []string{AppNameSpace},
+					TargetNamespaces: []string{"policy-test-*"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, policy)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, policy)).Should(Succeed())
+			}()
+			key := types.NamespacedName{Name: "policy-test", Namespace: AppNameSpace}
+			application := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, application)).Should(Succeed())
+			rejected := &appv1.Application{}
+			Eventually(func() appv1.ApplicationDeploymentState {
+				if err := k8sClient.Get(ctx, key, rejected); err != nil {
+					return ""
+				}
+				return rejected.Status.State
+			}, timeout, interval).Should(Equal(appv1.Rejected))
+			Expect(rejected.Status.PolicyViolations).To(ContainElement(ContainSubstring("target namespace kubefed-poc is not allowed by policy policy-test")))
+
+			Expect(k8sClient.Delete(ctx, rejected)).Should(Succeed())
+			Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, key, &appv1.Application{}))
+			}, timeout, interval).Should(BeTrue())
+		})
+	})
+
+	Context("When rolling out a new revision in steps ", func() {
+		It("Should advance to the next step once the step interval passed ", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "rollout-test", Namespace: AppNameSpace}
+			application := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+					ReleaseName: "rollout-test",
+					RolloutStrategy: &appv1.RolloutStrategy{
+						Steps:        []appv1.RolloutStep{{Clusters: []string{"cluster-a"}}, {}},
+						StepInterval: &metav1.Duration{Duration: 5 * time.Second},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, application)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, application)).Should(Succeed())
+			}()
+			Eventually(func() int {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return 0
+				}
+				return application.Status.CurrentRevision
+			}, timeout, interval).Should(Equal(1))
+
+			By("Changing the values of the chart")
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return err
+				}
+				application.Spec.Template.Chart.Values = &runtime.RawExtension{Raw: []byte(`{"replicaCount":2}`)}
+				return k8sClient.Update(ctx, application)
+			}, timeout, interval).Should(Succeed())
+
+			firstStep := &appv1.RolloutStatus{}
+			Eventually(func() *appv1.RolloutStatus {
+				if err := k8sClient.Get(ctx, key, application); err != nil || application.Status.Rollout == nil {
+					return firstStep
+				}
+				return application.Status.Rollout
+			}, timeout, interval).Should(And(
+				WithTransform(func(rollout *appv1.RolloutStatus) int { return rollout.Revision }, Equal(2)),
+				WithTransform(func(rollout *appv1.RolloutStatus) []string { return rollout.UpdatedClusters }, Equal([]string{"cluster-a"})),
+			))
+			firstStep = application.Status.Rollout.DeepCopy()
+			Expect(firstStep.Complete).To(BeFalse())
+			Expect(firstStep.StepStartedAt).ToNot(BeNil())
+
+			Eventually(func() bool {
+				if err := k8sClient.Get(ctx, key, application); err != nil || application.Status.Rollout == nil {
+					return false
+				}
+				return application.Status.Rollout.Complete
+			}, timeout, interval).Should(BeTrue())
+			Expect(application.Status.Rollout.UpdatedClusters).To(Equal([]string{"cluster-a", "cluster-b"}))
+			Expect(time.Since(firstStep.StepStartedAt.Time)).To(BeNumerically(">=", 5*time.Second))
+		})
+	})
+
+	Context("When a new revision keeps failing to deploy ", func() {
+		It("Should roll back to the last successful revision ", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "rollback-test", Namespace: AppNameSpace}
+			application := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+					ReleaseName: "rollback-test",
+					Rollback:    &appv1.RollbackPolicy{Enabled: true, FailureThreshold: 2},
+				},
+			}
+			Expect(k8sClient.Create(ctx, application)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, application)).Should(Succeed())
+			}()
+			Eventually(func() []appv1.RevisionHistory {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return nil
+				}
+				return application.Status.History
+			}, timeout, interval).Should(ContainElement(And(
+				WithTransform(func(history appv1.RevisionHistory) int { return history.Revision }, Equal(1)),
+				WithTransform(func(history appv1.RevisionHistory) string { return history.Status }, Equal(string(util.RevisionSucceeded))),
+			)))
+
+			By("Rendering a kind kubefed does not federate")
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return err
+				}
+				application.Spec.Template.Chart.Values = &runtime.RawExtension{Raw: []byte(`{"pdb":{"create":true}}`)}
+				return k8sClient.Update(ctx, application)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() appv1.ApplicationDeploymentState {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return ""
+				}
+				return application.Status.State
+			}, timeout, interval).Should(Equal(appv1.RolledBack))
+			Expect(application.Status.Rollback).ToNot(BeNil())
+			Expect(application.Status.Rollback.Revision).To(Equal(1))
+			Expect(application.Status.CurrentRevision).To(Equal(1))
+			Expect(application.Status.FailureCount).To(BeZero())
+		})
+	})
+
+	Context("When the application places federated objects on disallowed clusters ", func() {
+		It("Should reject the application naming the clusters ", func() {
+			ctx := context.Background()
+			policy := &appv1.ApplicationPolicy{
+				ObjectMeta: metav1.ObjectMeta{Name: "placement-test"},
+				Spec: appv1.ApplicationPolicySpec{
+					SourceNamespaces: []string{AppNameSpace},
+					Clusters:         []string{"cluster-a"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, policy)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, policy)).Should(Succeed())
+			}()
+			key := types.NamespacedName{Name: "placement-test", Namespace: AppNameSpace}
+			application := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+					ReleaseName: "placement-test",
+				},
+			}
+			Expect(k8sClient.Create(ctx, application)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, application)).Should(Succeed())
+			}()
+			Eventually(func() appv1.ApplicationDeploymentState {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return ""
+				}
+				return application.Status.State
+			}, timeout, interval).Should(Equal(appv1.Rejected))
+			condition := application.Status.GetCondition(appv1.PolicyAllowed)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+			Expect(condition.Message).To(ContainSubstring("clusters cluster-b are not allowed by policy placement-test"))
+		})
+	})
+
+	Context("When federated objects of the release are owned by another application ", func() {
+		It("Should report the clash without applying anything ", func() {
+			ctx := context.Background()
+			// the owning application is suspended so it never deploys the objects itself
+			owner := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: "other-application", Namespace: AppNameSpace},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+					Suspend: true,
+				},
+			}
+			Expect(k8sClient.Create(ctx, owner)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, owner)).Should(Succeed())
+			}()
+			federatedObject := func(kind string, name string) *unstructured.Unstructured {
+				object := &unstructured.Unstructured{}
+				object.SetAPIVersion("types.kubefed.io/v1beta1")
+				object.SetKind(kind)
+				object.SetNamespace("kubefed-poc")
+				object.SetName(name)
+				return object
+			}
+			owned := federatedObject("FederatedService", "clash-test-nginx")
+			owned.SetLabels(map[string]string{
+				appv1.ApplicationNameLabel:      "other-application",
+				appv1.ApplicationNamespaceLabel: AppNameSpace,
+			})
+			Expect(unstructured.SetNestedField(owned.Object, map[string]interface{}{}, "spec")).To(Succeed())
+			Expect(k8sClient.Create(ctx, owned)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, owned)).Should(Succeed())
+			}()
+
+			key := types.NamespacedName{Name: "clash-test", Namespace: AppNameSpace}
+			application := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+					ReleaseName:    "clash-test",
+					AdoptionPolicy: appv1.AdoptIfUnowned,
+				},
+			}
+			Expect(k8sClient.Create(ctx, application)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, application)).Should(Succeed())
+			}()
+			Eventually(func() appv1.ApplicationDeploymentState {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return ""
+				}
+				return application.Status.State
+			}, timeout, interval).Should(Equal(appv1.Rejected))
+			Expect(application.Status.Clashes).To(ContainElement(And(
+				WithTransform(func(clash appv1.OwnershipClash) string { return clash.Name }, Equal("clash-test-nginx")),
+				WithTransform(func(clash appv1.OwnershipClash) string { return clash.Owner }, Equal(AppNameSpace+"/other-application")),
+			)))
+			Expect(application.Status.FailureCount).To(BeZero())
+			condition := application.Status.GetCondition(appv1.ResourcesOwned)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Status).To(Equal(corev1.ConditionFalse))
+			Expect(condition.Message).To(ContainSubstring("FederatedService clash-test-nginx"))
+
+			By("Not applying the federated objects without a clash")
+			deployment := federatedObject("FederatedDeployment", "clash-test-nginx")
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "kubefed-poc", Name: "clash-test-nginx"}, deployment)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+			By("Keeping objects of another existing application with the Always adoption policy")
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return err
+				}
+				application.Spec.AdoptionPolicy = appv1.AdoptAlways
+				return k8sClient.Update(ctx, application)
+			}, timeout, interval).Should(Succeed())
+			Consistently(func() string {
+				if err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "kubefed-poc", Name: "clash-test-nginx"}, owned); err != nil {
+					return ""
+				}
+				return owned.GetLabels()[appv1.ApplicationNameLabel]
+			}, duration, interval).Should(Equal("other-application"))
+		})
+	})
+
+	Context("When an unlabeled federated object of the release was applied by the controller for another application ", func() {
+		It("Should report the clash ", func() {
+			ctx := context.Background()
+			unlabeled := &unstructured.Unstructured{}
+			unlabeled.SetAPIVersion("types.kubefed.io/v1beta1")
+			unlabeled.SetKind("FederatedService")
+			unlabeled.SetNamespace("kubefed-poc")
+			unlabeled.SetName("unlabeled-test-nginx")
+			Expect(unstructured.SetNestedField(unlabeled.Object, map[string]interface{}{}, "spec")).To(Succeed())
+			Expect(k8sClient.Patch(ctx, unlabeled, client.Apply, client.FieldOwner(util.FieldManager))).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, unlabeled)).Should(Succeed())
+			}()
+
+			key := types.NamespacedName{Name: "unlabeled-test", Namespace: AppNameSpace}
+			application := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+					ReleaseName: "unlabeled-test",
+				},
+			}
+			Expect(k8sClient.Create(ctx, application)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, application)).Should(Succeed())
+			}()
+			Eventually(func() appv1.ApplicationDeploymentState {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return ""
+				}
+				return application.Status.State
+			}, timeout, interval).Should(Equal(appv1.Rejected))
+			Expect(application.Status.Clashes).To(ContainElement(And(
+				WithTransform(func(clash appv1.OwnershipClash) string { return clash.Name }, Equal("unlabeled-test-nginx")),
+				WithTransform(func(clash appv1.OwnershipClash) string { return clash.Owner }, BeEmpty()),
+			)))
+		})
+	})
+
+	Context("When an application records more revisions than its history limit ", func() {
+		It("Should prune old revisions and roll back to a stored one ", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "history-test", Namespace: AppNameSpace}
+			limit := int32(2)
+			application := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+					ReleaseName:          "history-test",
+					RevisionHistoryLimit: &limit,
+				},
+			}
+			Expect(k8sClient.Create(ctx, application)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, application)).Should(Succeed())
+			}()
+			historyRevisions := func() []int {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return nil
+				}
+				var revisions []int
+				for _, history := range application.Status.History {
+					revisions = append(revisions, history.Revision)
+				}
+				return revisions
+			}
+			secretRevisions := func() []int {
+				var secrets corev1.SecretList
+				if err := k8sClient.List(ctx, &secrets, client.InNamespace(AppNameSpace), client.MatchingLabels{util.RevisionOwnerLabel: key.Name}); err != nil {
+					return nil
+				}
+				var revisions []int
+				for _, secret := range secrets.Items {
+					var number int
+					if _, err := fmt.Sscanf(secret.Labels[util.RevisionNumberLabel], "%d", &number); err == nil {
+						revisions = append(revisions, number)
+					}
+				}
+				sort.Ints(revisions)
+				return revisions
+			}
+			Eventually(func() int {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return 0
+				}
+				return application.Status.CurrentRevision
+			}, timeout, interval).Should(Equal(1))
+
+			By("Changing the values of the chart twice")
+			for _, replicas := range []int{2, 3} {
+				Eventually(func() error {
+					if err := k8sClient.Get(ctx, key, application); err != nil {
+						return err
+					}
+					application.Spec.Template.Chart.Values = &runtime.RawExtension{Raw: []byte(fmt.Sprintf(`{"replicaCount":%d}`, replicas))}
+					return k8sClient.Update(ctx, application)
+				}, timeout, interval).Should(Succeed())
+				Eventually(func() int {
+					if err := k8sClient.Get(ctx, key, application); err != nil {
+						return 0
+					}
+					return application.Status.CurrentRevision
+				}, timeout, interval).Should(Equal(replicas))
+			}
+			Eventually(historyRevisions, timeout, interval).Should(Equal([]int{2, 3}))
+			Eventually(secretRevisions, timeout, interval).Should(Equal([]int{2, 3}))
+
+			By("Rolling back to revision 2 with a history limit of 1")
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return err
+				}
+				rollbackTo := 2
+				limit = 1
+				application.Spec.RollbackTo = &rollbackTo
+				application.Spec.RevisionHistoryLimit = &limit
+				return k8sClient.Update(ctx, application)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() int {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return 0
+				}
+				return application.Status.CurrentRevision
+			}, timeout, interval).Should(Equal(2))
+			Expect(application.Status.State).To(Equal(appv1.Deployed))
+			// the latest revision is kept by the limit , the revision rolled back to since it is in use
+			Eventually(historyRevisions, timeout, interval).Should(Equal([]int{2, 3}))
+			Eventually(secretRevisions, timeout, interval).Should(Equal([]int{2, 3}))
+
+			deployment := &unstructured.Unstructured{}
+			deployment.SetAPIVersion("types.kubefed.io/v1beta1")
+			deployment.SetKind("FederatedDeployment")
+			Eventually(func() int {
+				if err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "kubefed-poc", Name: "history-test-nginx"}, deployment); err != nil {
+					return 0
+				}
+				return util.TemplateRevision(deployment)
+			}, timeout, interval).Should(Equal(2))
+			replicas, _, err := unstructured.NestedInt64(deployment.Object, "spec", "template", "spec", "replicas")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(replicas).To(Equal(int64(2)))
+		})
+	})
+
+	Context("When an application no longer renders or is deleted ", func() {
+		It("Should delete the federated objects it applied ", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "cleanup-test", Namespace: AppNameSpace}
+			application := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+							Values:    &runtime.RawExtension{Raw: []byte(`{"serverBlock":"server { listen 8080; }"}`)},
+						},
+					},
+					ReleaseName: "cleanup-test",
+				},
+			}
+			Expect(k8sClient.Create(ctx, application)).Should(Succeed())
+			federatedObject := func(kind string, name string) (*unstructured.Unstructured, types.NamespacedName) {
+				object := &unstructured.Unstructured{}
+				object.SetAPIVersion("types.kubefed.io/v1beta1")
+				object.SetKind(kind)
+				return object, types.NamespacedName{Namespace: "kubefed-poc", Name: name}
+			}
+			configMap, configMapKey := federatedObject("FederatedConfigMap", "cleanup-test-nginx-server-block")
+			deployment, deploymentKey := federatedObject("FederatedDeployment", "cleanup-test-nginx")
+			Eventually(func() error {
+				return k8sClient.Get(ctx, configMapKey, configMap)
+			}, timeout, interval).Should(Succeed())
+
+			By("Dropping the server block from the values")
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return err
+				}
+				application.Spec.Template.Chart.Values = nil
+				return k8sClient.Update(ctx, application)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, configMapKey, configMap))
+			}, timeout, interval).Should(BeTrue())
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).Should(Succeed())
+
+			By("Deleting the application")
+			Expect(k8sClient.Delete(ctx, application)).Should(Succeed())
+			Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, key, &appv1.Application{}))
+			}, timeout, interval).Should(BeTrue())
+			Expect(apierrors.IsNotFound(k8sClient.Get(ctx, deploymentKey, deployment))).To(BeTrue())
+		})
+	})
+
+	Context("When service accounts are required ", func() {
+		It("Should reject an application not naming one ", func() {
+			reconciler := &ApplicationReconciler{
+				Client:                k8sClient,
+				Config:                cfg,
+				Log:                   ctrl.Log.WithName("controllers").WithName("Application"),
+				Revisions:             util.NewSecretRevisionStore(k8sClient, k8sClient),
+				RequireServiceAccount: true,
+			}
+			application := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "service-account-test",
+					Namespace:  AppNameSpace,
+					Finalizers: []string{applicationFinalizer},
+				},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+				},
+			}
+			_, err := reconciler.reconcileApplication(application, reconciler.Log)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(application.Status.State).To(Equal(appv1.Rejected))
+			Expect(application.Status.CurrentRevision).To(BeZero())
+		})
+	})
+
+	Context("When the service account of an application may not create federated objects ", func() {
+		It("Should fail to apply them ", func() {
+			ctx := context.Background()
+			serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "restricted", Namespace: AppNameSpace}}
+			Expect(k8sClient.Create(ctx, serviceAccount)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, serviceAccount)).Should(Succeed())
+			}()
+			key := types.NamespacedName{Name: "restricted-test", Namespace: AppNameSpace}
+			application := &appv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+					ReleaseName:        "restricted-test",
+					ServiceAccountName: "restricted",
+				},
+			}
+			Expect(k8sClient.Create(ctx, application)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, application)).Should(Succeed())
+			}()
+			Eventually(func() int32 {
+				if err := k8sClient.Get(ctx, key, application); err != nil {
+					return 0
+				}
+				return application.Status.FailureCount
+			}, timeout, interval).Should(BeNumerically(">", 0))
+			Expect(application.Status.State).To(Equal(appv1.Errored))
+			Expect(application.Status.CurrentRevision).To(BeZero())
+			deployment := &unstructured.Unstructured{}
+			deployment.SetAPIVersion("types.kubefed.io/v1beta1")
+			deployment.SetKind("FederatedDeployment")
+			err := k8sClient.Get(ctx, types.NamespacedName{Namespace: "kubefed-poc", Name: "restricted-test-nginx"}, deployment)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+	})
 })
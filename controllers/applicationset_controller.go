@@ -0,0 +1,311 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+// kubeFedClusterKind is watched to regenerate the applications of cluster generators
+var kubeFedClusterKind = schema.GroupVersionKind{Group: "core.kubefed.io", Version: "v1beta1", Kind: "KubeFedCluster"}
+
+// templateError is an application set template that cannot be rendered , retrying does not help
+type templateError struct {
+	err error
+}
+
+func (err *templateError) Error() string {
+	return err.err.Error()
+}
+
+// ApplicationSetReconciler reconciles a ApplicationSet object
+type ApplicationSetReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Member clusters the cluster generators select from
+	Clusters util.ClusterSelector
+}
+
+// +kubebuilder:rbac:groups=federation.kubefed.fulliautomatix.site,resources=applicationsets,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=federation.kubefed.fulliautomatix.site,resources=applicationsets/status,verbs=get;update;patch
+
+func (r *ApplicationSetReconciler) Reconcile(req ctrl.Request) (result ctrl.Result, reterr error) {
+	context := context.Background()
+	log := r.Log.WithValues("applicationset", req.NamespacedName)
+	var set federationv1.ApplicationSet
+	err := r.Get(context, req.NamespacedName, &set)
+
+	if err != nil {
+		log.Error(err, "Unable to fetch application set")
+		// Skip if not found
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	defer func() {
+		err := r.Client.Update(context, &set)
+		if err != nil {
+			log.Error(err, "Unable to update status ")
+			if reterr == nil {
+				reterr = err
+			}
+		}
+	}()
+
+	applications, err := r.generateApplications(&set)
+	if err != nil {
+		log.Error(err, "Unable to generate applications")
+		set.Status.Message = err.Error()
+		if _, ok := err.(*templateError); ok {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	var names []string
+	for _, application := range applications {
+		if err := r.applyApplication(&set, application); err != nil {
+			set.Status.Message = err.Error()
+			return ctrl.Result{}, err
+		}
+		names = append(names, application.Name)
+	}
+	if err := r.deleteStaleApplications(&set, names, log); err != nil {
+		set.Status.Message = err.Error()
+		return ctrl.Result{}, err
+	}
+	sort.Strings(names)
+	set.Status.Applications = names
+	set.Status.Message = ""
+	return ctrl.Result{}, nil
+}
+
+// generateApplications renders the template for every parameter set of the generators
+func (r *ApplicationSetReconciler) generateApplications(set *federationv1.ApplicationSet) ([]*federationv1.Application, error) {
+	var parameters []util.Parameters
+	for _, generator := range set.Spec.Generators {
+		generated, err := r.generatorParameters(generator)
+		if err != nil {
+			return nil, err
+		}
+		parameters = append(parameters, generated...)
+	}
+	template, err := json.Marshal(set.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
+	var applications []*federationv1.Application
+	generated := map[string]bool{}
+	for _, params := range parameters {
+		application, err := renderApplicationTemplate(set, template, params)
+		if err != nil {
+			return nil, &templateError{err: err}
+		}
+		if generated[application.Name] {
+			return nil, &templateError{err: fmt.Errorf("Template generates application %s more than once , add parameters to its name", application.Name)}
+		}
+		generated[application.Name] = true
+		applications = append(applications, application)
+	}
+	return applications, nil
+}
+
+// generatorParameters are the parameter sets of a single generator
+func (r *ApplicationSetReconciler) generatorParameters(generator federationv1.ApplicationSetGenerator) ([]util.Parameters, error) {
+	if generator.Matrix == nil {
+		return r.baseGeneratorParameters(federationv1.ApplicationSetBaseGenerator{List: generator.List, Clusters: generator.Clusters})
+	}
+	var generated [][]util.Parameters
+	for _, nested := range generator.Matrix.Generators {
+		params, err := r.baseGeneratorParameters(nested)
+		if err != nil {
+			return nil, err
+		}
+		generated = append(generated, params)
+	}
+	params, err := util.MatrixParameters(generated...)
+	if err != nil {
+		return nil, &templateError{err: err}
+	}
+	return params, nil
+}
+
+func (r *ApplicationSetReconciler) baseGeneratorParameters(generator federationv1.ApplicationSetBaseGenerator) ([]util.Parameters, error) {
+	switch {
+	case generator.List != nil && generator.Clusters == nil:
+		var result []util.Parameters
+		for _, element := range generator.List.Elements {
+			params := util.Parameters{}
+			for key, value := range element {
+				params[key] = value
+			}
+			result = append(result, params)
+		}
+		return result, nil
+	case generator.Clusters != nil && generator.List == nil:
+		selector, err := metav1.LabelSelectorAsSelector(&generator.Clusters.Selector)
+		if err != nil {
+			return nil, &templateError{err: fmt.Errorf("Invalid cluster selector: %v", err)}
+		}
+		if r.Clusters == nil {
+			return nil, nil
+		}
+		clusters, err := r.Clusters.MatchingClusters(selector)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to list member clusters: %v", err)
+		}
+		return util.ClusterParameters(clusters), nil
+	default:
+		return nil, &templateError{err: fmt.Errorf("Every generator needs exactly one of list , clusters or matrix")}
+	}
+}
+
+// renderApplicationTemplate substitutes the parameters in the template of the application set
+func renderApplicationTemplate(set *federationv1.ApplicationSet, template []byte, params util.Parameters) (*federationv1.Application, error) {
+	substituted, err := util.SubstituteParameters(template, params)
+	if err != nil {
+		return nil, err
+	}
+	var rendered federationv1.ApplicationSetTemplate
+	if err := json.Unmarshal(substituted, &rendered); err != nil {
+		return nil, fmt.Errorf("Invalid application template: %v", err)
+	}
+	if rendered.Metadata.Name == "" {
+		return nil, fmt.Errorf("Application template has no name")
+	}
+	labels := map[string]string{}
+	for key, value := range rendered.Metadata.Labels {
+		labels[key] = value
+	}
+	labels[federationv1.ApplicationSetLabel] = set.Name
+	application := &federationv1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        rendered.Metadata.Name,
+			Namespace:   set.Namespace,
+			Labels:      labels,
+			Annotations: rendered.Metadata.Annotations,
+		},
+		Spec: rendered.Spec,
+	}
+	// default like the webhook does so unchanged applications are not updated on every reconcile
+	application.Default()
+	return application, nil
+}
+
+// applyApplication creates the generated application or updates it to the generated state
+func (r *ApplicationSetReconciler) applyApplication(set *federationv1.ApplicationSet, generated *federationv1.Application) error {
+	application := &federationv1.Application{ObjectMeta: metav1.ObjectMeta{Name: generated.Name, Namespace: generated.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(context.TODO(), r.Client, application, func() error {
+		if !application.CreationTimestamp.IsZero() && !metav1.IsControlledBy(application, set) {
+			return fmt.Errorf("Application %s already exists and is not owned by the application set", application.Name)
+		}
+		// labels and annotations added by others , e.g. a preview annotation , are kept
+		application.Labels = mergeStrings(application.Labels, generated.Labels)
+		application.Annotations = mergeStrings(application.Annotations, generated.Annotations)
+		application.Spec = generated.Spec
+		return controllerutil.SetControllerReference(set, application, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("Unable to apply application %s: %v", generated.Name, err)
+	}
+	return nil
+}
+
+// mergeStrings sets the generated keys in the existing map
+func mergeStrings(existing map[string]string, generated map[string]string) map[string]string {
+	if len(generated) == 0 {
+		return existing
+	}
+	if existing == nil {
+		existing = map[string]string{}
+	}
+	for key, value := range generated {
+		existing[key] = value
+	}
+	return existing
+}
+
+// deleteStaleApplications deletes the applications of the set no longer generated
+func (r *ApplicationSetReconciler) deleteStaleApplications(set *federationv1.ApplicationSet, generated []string, log logr.Logger) error {
+	var applications federationv1.ApplicationList
+	err := r.List(context.TODO(), &applications, client.InNamespace(set.Namespace), client.MatchingLabels{federationv1.ApplicationSetLabel: set.Name})
+	if err != nil {
+		return fmt.Errorf("Unable to list applications: %v", err)
+	}
+	for i := range applications.Items {
+		application := &applications.Items[i]
+		if containsString(generated, application.Name) || !metav1.IsControlledBy(application, set) {
+			continue
+		}
+		log.Info("Deleting application no longer generated", "name", application.Name)
+		if err := r.Delete(context.TODO(), application); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("Unable to delete application %s: %v", application.Name, err)
+		}
+	}
+	return nil
+}
+
+// clusterApplicationSetRequests maps a changed member cluster to every application set generating from clusters
+func (r *ApplicationSetReconciler) clusterApplicationSetRequests(object handler.MapObject) []reconcile.Request {
+	var sets federationv1.ApplicationSetList
+	if err := r.List(context.TODO(), &sets); err != nil {
+		r.Log.Error(err, "Unable to list application sets")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, set := range sets.Items {
+		if set.UsesClusters() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: set.Namespace, Name: set.Name}})
+		}
+	}
+	return requests
+}
+
+func (r *ApplicationSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(&federationv1.ApplicationSet{}).
+		Owns(&federationv1.Application{}).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	kubeFedCluster := &unstructured.Unstructured{}
+	kubeFedCluster.SetGroupVersionKind(kubeFedClusterKind)
+	// the cluster status is updated by kubefed all the time , only label changes matter
+	return c.Watch(&source.Kind{Type: kubeFedCluster}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(r.clusterApplicationSetRequests),
+	}, predicate.Funcs{UpdateFunc: federatedObjectChanged})
+}
@@ -0,0 +1,118 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "kubefed-application-controller/api/v1"
+)
+
+var _ = Describe("application set controller", func() {
+	const (
+		interval = time.Millisecond * 250
+		timeout  = time.Second * 30
+	)
+
+	Context("When generating applications from a list ", func() {
+		It("Should create them and delete the ones no longer generated ", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "set-test", Namespace: "default"}
+			set := &appv1.ApplicationSet{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: appv1.ApplicationSetSpec{
+					Generators: []appv1.ApplicationSetGenerator{{
+						List: &appv1.ListGenerator{Elements: []map[string]string{{"env": "dev"}, {"env": "prod"}}},
+					}},
+					Template: appv1.ApplicationSetTemplate{
+						Metadata: appv1.ApplicationSetTemplateMeta{
+							Name:   "set-test-{{env}}",
+							Labels: map[string]string{"env": "{{env}}"},
+						},
+						Spec: appv1.ApplicationSpec{
+							Type: "Helm",
+							Template: appv1.ApplicationTemplateSpec{
+								Chart: appv1.HelmChartSpec{
+									Name:      "nginx",
+									Namespace: "set-test-{{env}}",
+									Repo:      "https://charts.bitnami.com/bitnami",
+								},
+							},
+							// the generated applications are not deployed
+							Suspend: true,
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, set)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, set)).Should(Succeed())
+			}()
+			Eventually(func() []string {
+				if err := k8sClient.Get(ctx, key, set); err != nil {
+					return nil
+				}
+				return set.Status.Applications
+			}, timeout, interval).Should(Equal([]string{"set-test-dev", "set-test-prod"}))
+
+			dev := &appv1.Application{}
+			devKey := types.NamespacedName{Name: "set-test-dev", Namespace: key.Namespace}
+			Expect(k8sClient.Get(ctx, devKey, dev)).Should(Succeed())
+			Expect(dev.Labels).To(HaveKeyWithValue(appv1.ApplicationSetLabel, "set-test"))
+			Expect(dev.Labels).To(HaveKeyWithValue("env", "dev"))
+			Expect(dev.Spec.Template.Chart.Namespace).To(Equal("set-test-dev"))
+			Expect(metav1.IsControlledBy(dev, set)).To(BeTrue())
+
+			By("Labeling a generated application")
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, devKey, dev); err != nil {
+					return err
+				}
+				dev.Labels["team"] = "web"
+				return k8sClient.Update(ctx, dev)
+			}, timeout, interval).Should(Succeed())
+
+			By("Removing an element of the list")
+			Eventually(func() error {
+				if err := k8sClient.Get(ctx, key, set); err != nil {
+					return err
+				}
+				set.Spec.Generators[0].List.Elements = []map[string]string{{"env": "dev"}}
+				return k8sClient.Update(ctx, set)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: "set-test-prod", Namespace: key.Namespace}, &appv1.Application{}))
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() []string {
+				if err := k8sClient.Get(ctx, key, set); err != nil {
+					return nil
+				}
+				return set.Status.Applications
+			}, timeout, interval).Should(Equal([]string{"set-test-dev"}))
+			Expect(k8sClient.Get(ctx, devKey, dev)).Should(Succeed())
+			Expect(dev.Labels).To(HaveKeyWithValue("team", "web"))
+			Expect(dev.Labels).To(HaveKeyWithValue(appv1.ApplicationSetLabel, "set-test"))
+		})
+	})
+})
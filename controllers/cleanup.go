@@ -0,0 +1,90 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+// deleteFederatedResources deletes the federated objects of every revision once the application is deleted ,
+// impersonating its service account like the applies
+func (r *ApplicationReconciler) deleteFederatedResources(application *federationv1.Application, log logr.Logger) error {
+	created, err := r.createdResources(application, 0)
+	if err != nil {
+		return err
+	}
+	dynamicClient, err := r.newDeployer(application)
+	if err != nil {
+		return fmt.Errorf("Unable to create a dynamic client")
+	}
+	for reference := range created {
+		if err := r.deleteOwnedResource(application, dynamicClient, reference, log); err != nil {
+			return fmt.Errorf("Unable to delete %s %s: %v", reference.Kind, reference.Name, err)
+		}
+	}
+	return nil
+}
+
+// pruneFederatedResources deletes the federated objects of other revisions no longer part of the applied inventory
+func (r *ApplicationReconciler) pruneFederatedResources(application *federationv1.Application, dynamicClient util.DynamicClient, revision int, inventory []util.ResourceReference) error {
+	created, err := r.createdResources(application, revision)
+	if err != nil {
+		return err
+	}
+	for _, reference := range inventory {
+		delete(created, reference)
+	}
+	log := r.Log.WithValues("application", application.Namespace+"/"+application.Name)
+	for reference := range created {
+		if err := r.deleteOwnedResource(application, dynamicClient, reference, log); err != nil {
+			return fmt.Errorf("Unable to prune %s %s: %v", reference.Kind, reference.Name, err)
+		}
+	}
+	return nil
+}
+
+// deleteOwnedResource deletes a federated object labeled with the application ,
+// objects the service account may not delete are left behind rather than deleted with the rights of the controller
+func (r *ApplicationReconciler) deleteOwnedResource(application *federationv1.Application, dynamicClient util.DynamicClient, reference util.ResourceReference, log logr.Logger) error {
+	object := unstructured.Unstructured{}
+	object.SetAPIVersion(reference.APIVersion)
+	object.SetKind(reference.Kind)
+	object.SetName(reference.Name)
+	live, err := dynamicClient.Get(object, reference.Namespace)
+	if err == nil {
+		// every apply labels the objects , an unlabeled object was never applied for the application
+		if resourceOwner(live) != application.Namespace+"/"+application.Name {
+			return nil
+		}
+		err = dynamicClient.Delete(object, reference.Namespace)
+	}
+	if errors.IsForbidden(err) {
+		log.Info("Not permitted to delete federated object , leaving it", "kind", reference.Kind, "name", reference.Name, "error", err.Error())
+		return nil
+	}
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
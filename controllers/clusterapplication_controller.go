@@ -0,0 +1,158 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+// clusterApplicationRevisionPrefix keeps the revisions of cluster applications apart from those of
+// applications with the same name in the cluster application namespace
+const clusterApplicationRevisionPrefix = "clusterapplication."
+
+// ClusterApplicationReconciler reconciles a ClusterApplication object through the application pipeline
+type ClusterApplicationReconciler struct {
+	*ApplicationReconciler
+	Log logr.Logger
+}
+
+// +kubebuilder:rbac:groups=federation.kubefed.fulliautomatix.site,resources=clusterapplications,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=federation.kubefed.fulliautomatix.site,resources=clusterapplications/status,verbs=get;update;patch
+
+func (r *ClusterApplicationReconciler) Reconcile(req ctrl.Request) (result ctrl.Result, reterr error) {
+	context := context.Background()
+	log := r.Log.WithValues("clusterapplication", req.Name)
+	var clusterApplication federationv1.ClusterApplication
+	err := r.Get(context, req.NamespacedName, &clusterApplication)
+
+	if err != nil {
+		log.Error(err, "Unable to fetch cluster application")
+		// Skip if not found
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	application := clusterApplication.Application()
+	defer func() {
+		clusterApplication.ObjectMeta.Finalizers = application.ObjectMeta.Finalizers
+		err := r.updateApplication(&clusterApplication, func() { clusterApplication.Status = application.Status })
+		if err != nil {
+			log.Error(err, "Unable to update status ")
+			if reterr == nil {
+				reterr = err
+			}
+		}
+	}()
+	return r.reconcileApplication(application, log)
+}
+
+func (r *ClusterApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		For(&federationv1.ClusterApplication{}).
+		Build(r)
+	if err != nil {
+		return err
+	}
+	for _, gvk := range r.FederatedKinds {
+		federatedType := &unstructured.Unstructured{}
+		federatedType.SetGroupVersionKind(gvk)
+		err = c.Watch(&source.Kind{Type: federatedType}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(ownerClusterApplicationRequests),
+		}, predicate.Funcs{UpdateFunc: federatedObjectChanged})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownerClusterApplicationRequests maps a generated federated object to the cluster application owning it
+func ownerClusterApplicationRequests(object handler.MapObject) []reconcile.Request {
+	labels := object.Meta.GetLabels()
+	name, ok := labels[federationv1.ApplicationNameLabel]
+	if !ok || labels[federationv1.ApplicationNamespaceLabel] != "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name}}}
+}
+
+// clusterScoped tells if the application is a cluster application reconciled as an application
+func clusterScoped(application *federationv1.Application) bool {
+	return application.Namespace == ""
+}
+
+// ownerReference references the application or cluster application owning the revisions
+func ownerReference(application *federationv1.Application) metav1.OwnerReference {
+	kind := "Application"
+	if clusterScoped(application) {
+		kind = "ClusterApplication"
+	}
+	return *metav1.NewControllerRef(application, federationv1.GroupVersion.WithKind(kind))
+}
+
+// applicationNamespace is the namespace holding the revisions and the impersonated service account
+// of the application , cluster applications use the cluster application namespace
+func (r *ApplicationReconciler) applicationNamespace(application *federationv1.Application) string {
+	if clusterScoped(application) {
+		return r.ClusterApplicationNamespace
+	}
+	return application.Namespace
+}
+
+// applicationRevisions are the revisions of a single application in the revision store
+type applicationRevisions struct {
+	store     util.RevisionStore
+	namespace string
+	owner     string
+}
+
+func (r *ApplicationReconciler) revisions(application *federationv1.Application) applicationRevisions {
+	owner := application.Name
+	if clusterScoped(application) {
+		owner = clusterApplicationRevisionPrefix + owner
+	}
+	return applicationRevisions{store: r.Revisions, namespace: r.applicationNamespace(application), owner: owner}
+}
+
+func (revisions applicationRevisions) List() ([]*util.Revision, error) {
+	return revisions.store.List(revisions.namespace, revisions.owner)
+}
+
+func (revisions applicationRevisions) Create(ownerRef metav1.OwnerReference, revision *util.Revision) error {
+	return revisions.store.Create(revisions.namespace, revisions.owner, ownerRef, revision)
+}
+
+func (revisions applicationRevisions) Update(revision *util.Revision) error {
+	return revisions.store.Update(revisions.namespace, revisions.owner, revision)
+}
+
+func (revisions applicationRevisions) Delete(number int) error {
+	return revisions.store.Delete(revisions.namespace, revisions.owner, number)
+}
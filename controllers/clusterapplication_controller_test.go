@@ -0,0 +1,99 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+var _ = Describe("cluster application controller", func() {
+	const (
+		interval = time.Millisecond * 250
+		timeout  = time.Second * 30
+	)
+
+	Context("When creating a cluster application ", func() {
+		It("Should deploy it through the application pipeline and delete it ", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "cluster-application-test"}
+			clusterApplication := &appv1.ClusterApplication{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name},
+				Spec: appv1.ApplicationSpec{
+					Type: "Helm",
+					Template: appv1.ApplicationTemplateSpec{
+						Chart: appv1.HelmChartSpec{
+							Name:      "nginx",
+							Namespace: "kubefed-poc",
+							Repo:      "https://charts.bitnami.com/bitnami",
+						},
+					},
+					ReleaseName: "cluster-application-test",
+				},
+			}
+			Expect(k8sClient.Create(ctx, clusterApplication)).Should(Succeed())
+			Eventually(func() appv1.ApplicationDeploymentState {
+				if err := k8sClient.Get(ctx, key, clusterApplication); err != nil {
+					return ""
+				}
+				return clusterApplication.Status.State
+			}, timeout, interval).Should(Equal(appv1.Deployed))
+			Expect(clusterApplication.Status.CurrentRevision).To(Equal(1))
+			Expect(clusterApplication.Finalizers).To(ContainElement(applicationFinalizer))
+
+			By("Labeling the federated objects with the cluster application")
+			deployment := &unstructured.Unstructured{}
+			deployment.SetAPIVersion("types.kubefed.io/v1beta1")
+			deployment.SetKind("FederatedDeployment")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: "kubefed-poc", Name: "cluster-application-test-nginx"}, deployment)).Should(Succeed())
+			Expect(deployment.GetLabels()).To(HaveKeyWithValue(appv1.ApplicationNameLabel, key.Name))
+			Expect(deployment.GetLabels()).To(HaveKeyWithValue(appv1.ApplicationNamespaceLabel, ""))
+
+			By("Recording the revisions in the cluster application namespace")
+			var revisions corev1.SecretList
+			Expect(k8sClient.List(ctx, &revisions, client.InNamespace("default"),
+				client.MatchingLabels{util.RevisionOwnerLabel: clusterApplicationRevisionPrefix + key.Name})).Should(Succeed())
+			Expect(revisions.Items).To(HaveLen(1))
+
+			By("Deleting the cluster application")
+			Expect(k8sClient.Delete(ctx, clusterApplication)).Should(Succeed())
+			Eventually(func() bool {
+				return apierrors.IsNotFound(k8sClient.Get(ctx, key, &appv1.ClusterApplication{}))
+			}, timeout, interval).Should(BeTrue())
+			Eventually(func() []corev1.Secret {
+				var revisions corev1.SecretList
+				if err := k8sClient.List(ctx, &revisions, client.InNamespace("default"),
+					client.MatchingLabels{util.RevisionOwnerLabel: clusterApplicationRevisionPrefix + key.Name}); err != nil {
+					return nil
+				}
+				return revisions.Items
+			}, timeout, interval).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,95 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"reflect"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+// PrepareFederatedResources labels the federated resources as generated for the application
+// and drops the fields released to other field managers
+func PrepareFederatedResources(application *federationv1.Application, fedResources []*unstructured.Unstructured) {
+	for _, fedResource := range fedResources {
+		if policy := application.Spec.ApplyPolicy; policy != nil {
+			for _, released := range policy.ReleaseFields {
+				if released.Kind == "" || released.Kind == fedResource.GetKind() {
+					util.RemoveFields(fedResource, []string{released.Path})
+				}
+			}
+		}
+		labels := fedResource.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[federationv1.ApplicationNameLabel] = application.Name
+		labels[federationv1.ApplicationNamespaceLabel] = application.Namespace
+		fedResource.SetLabels(labels)
+	}
+}
+
+// detectDrift records federated objects that were changed or deleted since they were applied ,
+// returning whether the desired state should be re-applied
+func (r *ApplicationReconciler) detectDrift(application *federationv1.Application, dynamicClient util.DynamicClient, fedResources []*unstructured.Unstructured, namespace string, log logr.Logger) (bool, error) {
+	drifts, err := util.DetectDrift(dynamicClient, fedResources, namespace)
+	if err != nil {
+		return false, err
+	}
+	correct := application.Spec.DriftPolicy != federationv1.DriftReport
+	application.Status.Drift = nil
+	for _, drift := range drifts {
+		log.Info("Detected drift of federated object", "kind", drift.Resource.Kind, "name", drift.Resource.Name, "deleted", drift.Deleted, "fields", drift.Fields)
+		application.Status.Drift = append(application.Status.Drift, federationv1.ResourceDrift{
+			ResourceReference: federationv1.ResourceReference(drift.Resource),
+			Deleted:           drift.Deleted,
+			Fields:            drift.Fields,
+			Corrected:         correct,
+		})
+	}
+	return len(drifts) == 0 || correct, nil
+}
+
+// ownerApplicationRequests maps a generated federated object to the application owning it
+func ownerApplicationRequests(object handler.MapObject) []reconcile.Request {
+	labels := object.Meta.GetLabels()
+	name, ok := labels[federationv1.ApplicationNameLabel]
+	if !ok || labels[federationv1.ApplicationNamespaceLabel] == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Name:      name,
+		Namespace: labels[federationv1.ApplicationNamespaceLabel],
+	}}}
+}
+
+// federatedObjectChanged ignores updates that only touch the status of a federated object
+func federatedObjectChanged(e event.UpdateEvent) bool {
+	if e.MetaOld == nil || e.MetaNew == nil {
+		return true
+	}
+	return e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() ||
+		!reflect.DeepEqual(e.MetaOld.GetLabels(), e.MetaNew.GetLabels())
+}
@@ -0,0 +1,187 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+const (
+	defaultRepositoryInterval = 10 * time.Minute
+	// maxRepositoryChartVersions keeps the status of large repositories within the object size limit
+	maxRepositoryChartVersions = 10
+)
+
+// HelmRepositoryReconciler reconciles a HelmRepository object
+type HelmRepositoryReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// Chart repositories applications may use , other repositories are not fetched
+	Repositories federationv1.RepositoryPolicy
+	// ConfigMap holding additional repository patterns , read with the APIReader
+	RepositoryPolicyConfigMap types.NamespacedName
+	APIReader                 client.Reader
+}
+
+// +kubebuilder:rbac:groups=federation.kubefed.fulliautomatix.site,resources=helmrepositories,verbs=get;list;watch
+// +kubebuilder:rbac:groups=federation.kubefed.fulliautomatix.site,resources=helmrepositories/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get
+
+func (r *HelmRepositoryReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	context := context.Background()
+	log := r.Log.WithValues("helmrepository", req.NamespacedName)
+	var repository federationv1.HelmRepository
+	err := r.Get(context, req.NamespacedName, &repository)
+
+	if err != nil {
+		log.Error(err, "Unable to fetch helm repository")
+		// Skip if not found
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	interval := defaultRepositoryInterval
+	if repository.Spec.Interval != nil {
+		interval = repository.Spec.Interval.Duration
+	}
+	index, err := r.fetchIndex(&repository)
+	if err != nil {
+		log.Error(err, "Unable to refresh repository index")
+		repository.Status.Message = err.Error()
+	} else {
+		if index.Digest != repository.Status.IndexDigest {
+			log.Info("Repository index changed", "digest", index.Digest)
+		}
+		repository.Status.Charts = nil
+		for _, name := range index.ChartNames() {
+			versions := index.Charts[name]
+			if len(versions) > maxRepositoryChartVersions {
+				versions = versions[:maxRepositoryChartVersions]
+			}
+			repository.Status.Charts = append(repository.Status.Charts, federationv1.RepositoryChart{Name: name, Versions: versions})
+		}
+		repository.Status.IndexDigest = index.Digest
+		repository.Status.LastRefreshed = &metav1.Time{Time: time.Now()}
+		repository.Status.Message = ""
+	}
+	if err := r.Status().Update(context, &repository); err != nil {
+		log.Error(err, "Unable to update status ")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// fetchIndex downloads the index of the repository with its credentials
+func (r *HelmRepositoryReconciler) fetchIndex(repository *federationv1.HelmRepository) (*util.RepositoryIndex, error) {
+	resolved, err := federationv1.ResolveChartRepository(r.Client, repository.Namespace, federationv1.HelmChartSpec{RepositoryRef: repository.Name})
+	if err != nil {
+		return nil, err
+	}
+	// namespace users must not make the controller request urls applications may not use
+	policy, err := federationv1.LoadRepositoryPolicy(r.APIReader, r.Repositories, r.RepositoryPolicyConfigMap)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.Check(resolved.URL); err != nil {
+		return nil, err
+	}
+	return util.FetchRepositoryIndex(repository.Namespace+"-"+repository.Name, resolved.URL, repositoryCredentials(resolved))
+}
+
+func (r *HelmRepositoryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// the status is written on every refresh , only spec changes and changed credentials need an immediate refresh
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&federationv1.HelmRepository{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: handler.ToRequestsFunc(r.secretRepositoryRequests),
+		}).
+		WithEventFilter(predicate.Funcs{UpdateFunc: repositorySpecChanged}).
+		Complete(r)
+}
+
+// secretRepositoryRequests maps a secret to the helm repositories of its namespace using it as credentials
+func (r *HelmRepositoryReconciler) secretRepositoryRequests(object handler.MapObject) []reconcile.Request {
+	var repositories federationv1.HelmRepositoryList
+	if err := r.List(context.TODO(), &repositories, client.InNamespace(object.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "Unable to list helm repositories")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, repository := range repositories.Items {
+		if repository.Spec.SecretRef != nil && repository.Spec.SecretRef.Name == object.Meta.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: repository.Namespace, Name: repository.Name}})
+		}
+	}
+	return requests
+}
+
+// repositorySpecChanged ignores status updates of helm repositories , updates of other objects pass
+func repositorySpecChanged(e event.UpdateEvent) bool {
+	if _, ok := e.ObjectNew.(*federationv1.HelmRepository); !ok {
+		return true
+	}
+	return predicate.GenerationChangedPredicate{}.Update(e)
+}
+
+// repositoryCredentials are the basic auth credentials of the resolved chart repository
+func repositoryCredentials(repository federationv1.ChartRepository) util.RepositoryCredentials {
+	return util.RepositoryCredentials{Username: repository.Username, Password: repository.Password}
+}
+
+// repositoryApplicationRequests maps a helm repository to the applications of its namespace referencing it
+func (r *ApplicationReconciler) repositoryApplicationRequests(object handler.MapObject) []reconcile.Request {
+	var applications federationv1.ApplicationList
+	if err := r.List(context.TODO(), &applications, client.InNamespace(object.Meta.GetNamespace())); err != nil {
+		r.Log.Error(err, "Unable to list applications")
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, application := range applications.Items {
+		if application.Spec.Template.Chart.RepositoryRef == object.Meta.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: application.Namespace, Name: application.Name}})
+		}
+	}
+	return requests
+}
+
+// repositoryIndexChanged ignores refreshes of a helm repository that did not change its index
+func repositoryIndexChanged(e event.UpdateEvent) bool {
+	oldRepository, oldOk := e.ObjectOld.(*federationv1.HelmRepository)
+	newRepository, newOk := e.ObjectNew.(*federationv1.HelmRepository)
+	if !oldOk || !newOk {
+		return true
+	}
+	return oldRepository.Status.IndexDigest != newRepository.Status.IndexDigest
+}
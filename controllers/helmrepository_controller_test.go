@@ -0,0 +1,58 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	appv1 "kubefed-application-controller/api/v1"
+)
+
+var _ = Describe("helm repository controller", func() {
+	const (
+		interval = time.Millisecond * 250
+		timeout  = time.Second * 30
+	)
+
+	Context("When a helm repository is denied by the repository policy ", func() {
+		It("Should not fetch its index ", func() {
+			ctx := context.Background()
+			key := types.NamespacedName{Name: "denied-repository", Namespace: "default"}
+			repository := &appv1.HelmRepository{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec:       appv1.HelmRepositorySpec{URL: "https://denied.example.com/charts"},
+			}
+			Expect(k8sClient.Create(ctx, repository)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, repository)).Should(Succeed())
+			}()
+			Eventually(func() string {
+				if err := k8sClient.Get(ctx, key, repository); err != nil {
+					return ""
+				}
+				return repository.Status.Message
+			}, timeout, interval).Should(ContainSubstring("is denied by pattern https://denied.example.com/*"))
+			Expect(repository.Status.LastRefreshed).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,164 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+// policyViolationError is returned when the application deploys what its application policies do not allow
+type policyViolationError struct {
+	violations []string
+}
+
+func (err *policyViolationError) Error() string {
+	return fmt.Sprintf("Application violates its application policies: %s", strings.Join(err.violations, " , "))
+}
+
+// rejectPolicyViolation rejects the application on a policy violation , it is reconciled again once its
+// spec or the policies change
+func (r *ApplicationReconciler) rejectPolicyViolation(application *federationv1.Application, err error, log logr.Logger) (ctrl.Result, error) {
+	violation, ok := err.(*policyViolationError)
+	if !ok {
+		application.Status.State = federationv1.Errored
+		return ctrl.Result{}, err
+	}
+	log.Info("Application is not allowed by its application policies", "violations", violation.violations)
+	application.Status.State = federationv1.Rejected
+	application.Status.PolicyViolations = violation.violations
+	application.Status.SetCondition(federationv1.ApplicationCondition{
+		Type:    federationv1.PolicyAllowed,
+		Status:  corev1.ConditionFalse,
+		Reason:  "PolicyViolation",
+		Message: strings.Join(violation.violations, " , "),
+	})
+	return ctrl.Result{}, nil
+}
+
+// applicationPolicies returns the application policies constraining the namespace of the application ,
+// cluster applications are managed by platform admins and not constrained by any policy
+func (r *ApplicationReconciler) applicationPolicies(application *federationv1.Application) ([]federationv1.ApplicationPolicy, error) {
+	if clusterScoped(application) {
+		return nil, nil
+	}
+	var policies federationv1.ApplicationPolicyList
+	if err := r.List(context.TODO(), &policies); err != nil {
+		return nil, fmt.Errorf("Unable to list application policies: %v", err)
+	}
+	var result []federationv1.ApplicationPolicy
+	for _, policy := range policies.Items {
+		if policy.AppliesTo(application.Namespace) {
+			result = append(result, policy)
+		}
+	}
+	return result, nil
+}
+
+// checkPolicies fails with a policyViolationError when the spec of the application is not allowed
+func (r *ApplicationReconciler) checkPolicies(application *federationv1.Application) error {
+	policies, err := r.applicationPolicies(application)
+	if err != nil {
+		return err
+	}
+	var violations []string
+	for _, policy := range policies {
+		violations = append(violations, policy.Violations(application)...)
+	}
+	if len(violations) > 0 {
+		return &policyViolationError{violations: violations}
+	}
+	return nil
+}
+
+// enforcePolicies fails when the application generates federated kinds or places federated objects
+// on member clusters its policies do not allow
+func (r *ApplicationReconciler) enforcePolicies(application *federationv1.Application, fedResources []*unstructured.Unstructured) error {
+	policies, err := r.applicationPolicies(application)
+	if err != nil {
+		return err
+	}
+	var violations []string
+	restrictClusters := false
+	for _, policy := range policies {
+		for _, fedResource := range fedResources {
+			if !policy.AllowsKind(fedResource.GetKind()) {
+				violations = append(violations, fmt.Sprintf("federated kind %s of %s is not allowed by policy %s", fedResource.GetKind(), fedResource.GetName(), policy.Name))
+			}
+		}
+		restrictClusters = restrictClusters || len(policy.Spec.Clusters) > 0
+	}
+	if restrictClusters {
+		placement, err := util.PlacementClusters(fedResources, r.Clusters)
+		if err != nil {
+			return err
+		}
+		for _, policy := range policies {
+			var disallowed []string
+			for _, cluster := range placement {
+				if !policy.AllowsCluster(cluster) {
+					disallowed = append(disallowed, cluster)
+				}
+			}
+			if len(disallowed) > 0 {
+				violations = append(violations, fmt.Sprintf("clusters %s are not allowed by policy %s", strings.Join(disallowed, ","), policy.Name))
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return &policyViolationError{violations: violations}
+	}
+	application.Status.SetCondition(federationv1.ApplicationCondition{
+		Type:   federationv1.PolicyAllowed,
+		Status: corev1.ConditionTrue,
+		Reason: "PolicyAllowed",
+	})
+	return nil
+}
+
+// policyApplicationRequests maps a changed application policy to every application it may constrain
+func (r *ApplicationReconciler) policyApplicationRequests(object handler.MapObject) []reconcile.Request {
+	policy, ok := object.Object.(*federationv1.ApplicationPolicy)
+	if !ok {
+		return nil
+	}
+	var applications federationv1.ApplicationList
+	if err := r.List(context.TODO(), &applications, client.InNamespace("")); err != nil {
+		r.Log.Error(err, "Unable to list applications for application policy", "policy", policy.Name)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, application := range applications.Items {
+		if policy.AppliesTo(application.Namespace) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: application.Namespace, Name: application.Name}})
+		}
+	}
+	return requests
+}
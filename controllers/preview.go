@@ -0,0 +1,105 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+// previewApplication renders the application and dry run applies it , recording the changes
+// it would make in the status without persisting anything
+func (r *ApplicationReconciler) previewApplication(application *federationv1.Application, inputs util.RevisionInputs, log logr.Logger) (ctrl.Result, error) {
+	_, federatedManifest, err := r.renderApplication(application, inputs, log)
+	if _, ok := err.(*util.ValuesError); ok {
+		application.Status.State = federationv1.Rejected
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		application.Status.State = federationv1.Errored
+		return ctrl.Result{}, err
+	}
+	fedResources, err := util.ParseManifest(federatedManifest)
+	if err != nil {
+		application.Status.State = federationv1.Errored
+		return ctrl.Result{}, err
+	}
+	if current := application.Status.CurrentRevision; current != 0 {
+		// the revision annotation only differs from the deployed objects when the output changes
+		for _, fedResource := range fedResources {
+			if err := util.SetTemplateRevision(fedResource, current); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+	}
+	PrepareFederatedResources(application, fedResources)
+
+	previous, err := r.currentInventory(application)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	deployer, err := r.newDeployer(application)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("Unable to create a dynamic client")
+	}
+	preview, err := util.PreviewChanges(deployer, fedResources, inputs.Namespace, previous)
+	if err != nil {
+		log.Error(err, "Unable to preview application")
+		application.Status.State = federationv1.Errored
+		return ctrl.Result{}, err
+	}
+
+	status := &federationv1.PreviewStatus{GeneratedAt: metav1.Now()}
+	for _, added := range preview.Added {
+		status.Added = append(status.Added, federationv1.ResourceReference(added))
+	}
+	for _, changed := range preview.Changed {
+		status.Changed = append(status.Changed, federationv1.ResourceChange{
+			ResourceReference: federationv1.ResourceReference(changed.Resource),
+			Fields:            changed.Fields,
+		})
+	}
+	for _, removed := range preview.Removed {
+		status.Removed = append(status.Removed, federationv1.ResourceReference(removed))
+	}
+	application.Status.Preview = status
+	application.Status.State = federationv1.Previewed
+	return ctrl.Result{}, nil
+}
+
+// currentInventory lists the federated objects of the revision currently deployed
+func (r *ApplicationReconciler) currentInventory(application *federationv1.Application) ([]util.ResourceReference, error) {
+	if application.Status.CurrentRevision == 0 {
+		return nil, nil
+	}
+	revisions, err := r.revisions(application).List()
+	if err != nil {
+		return nil, err
+	}
+	for _, revision := range revisions {
+		if revision.Number == application.Status.CurrentRevision {
+			return revision.Inventory, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,261 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+const (
+	defaultFailureThreshold     = 3
+	defaultRevisionHistoryLimit = 10
+)
+
+// recordRevisionError is returned when the revision cannot be stored , it is retried without counting
+// as a failure of the deployment
+type recordRevisionError struct {
+	err error
+}
+
+func (err *recordRevisionError) Error() string {
+	return fmt.Sprintf("Unable to record revision: %v", err.err)
+}
+
+// recordRevision stores the rendered output as a new revision , unless it matches the latest one
+func (r *ApplicationReconciler) recordRevision(application federationv1.Application, inputs util.RevisionInputs, rendered *util.RenderedChart, federatedManifest string) (*util.Revision, error) {
+	fedResources, err := util.ParseManifest(&federatedManifest)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := r.revisions(&application).List()
+	if err != nil {
+		return nil, err
+	}
+	inputsHash := inputs.Hash()
+	number := 1
+	if len(revisions) > 0 {
+		latest := revisions[len(revisions)-1]
+		if latest.InputsHash == inputsHash && latest.FederatedManifest == federatedManifest {
+			return latest, nil
+		}
+		number = latest.Number + 1
+	}
+	revision := &util.Revision{
+		Number:            number,
+		Inputs:            inputs,
+		InputsHash:        inputsHash,
+		ChartVersion:      rendered.ChartVersion,
+		Manifest:          rendered.Manifest,
+		FederatedManifest: federatedManifest,
+		Inventory:         util.NewInventory(fedResources, inputs.Namespace),
+		Status:            util.RevisionPending,
+		Timestamp:         metav1.Now(),
+	}
+	if err := r.revisions(&application).Create(ownerReference(&application), revision); err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// recordRevisionHealth marks the revision as succeeded once kubefed propagated it and the workloads
+// of the member clusters run it healthy , and rolls back when that takes longer than the rollback policy allows
+func (r *ApplicationReconciler) recordRevisionHealth(application *federationv1.Application, inputs util.RevisionInputs, revision *util.Revision, log logr.Logger) error {
+	if revision.Status != util.RevisionPending {
+		return nil
+	}
+	// the health of the member clusters only counts workloads showing the revision
+	healthy := propagated(application) && (r.HealthChecker == nil || application.Status.Health == federationv1.Healthy)
+	if healthy && rolloutInProgress(application, revision) {
+		// the revision only succeeds once it runs on all member clusters
+		return nil
+	}
+	if healthy {
+		revision.Status = util.RevisionSucceeded
+		return r.revisions(application).Update(revision)
+	}
+
+	policy := application.Spec.Rollback
+	if policy == nil || !policy.Enabled || policy.HealthTimeout == nil {
+		return nil
+	}
+	if time.Since(revision.Timestamp.Time) < policy.HealthTimeout.Duration {
+		return nil
+	}
+	revision.Status = util.RevisionFailed
+	if err := r.revisions(application).Update(revision); err != nil {
+		return err
+	}
+	reason := fmt.Sprintf("Workloads of revision %d not healthy after %s: %s", revision.Number, policy.HealthTimeout.Duration, application.Status.Health)
+	if !propagated(application) {
+		reason = fmt.Sprintf("Revision %d not propagated after %s", revision.Number, policy.HealthTimeout.Duration)
+	}
+	return r.rollback(application, inputs, revision, reason, log)
+}
+
+// handleDeploymentFailure counts the failure and rolls back once the failure threshold is reached
+func (r *ApplicationReconciler) handleDeploymentFailure(application *federationv1.Application, inputs util.RevisionInputs, revision *util.Revision, deployErr error, log logr.Logger) (ctrl.Result, error) {
+	application.Status.State = federationv1.Errored
+	application.Status.FailureCount++
+
+	if revision != nil && revision.Status == util.RevisionPending {
+		revision.Status = util.RevisionFailed
+		if err := r.revisions(application).Update(revision); err != nil {
+			log.Error(err, "Unable to mark revision as failed", "revision", revision.Number)
+		}
+	}
+
+	policy := application.Spec.Rollback
+	if policy == nil || !policy.Enabled {
+		return ctrl.Result{}, deployErr
+	}
+	threshold := policy.FailureThreshold
+	if threshold == 0 {
+		threshold = defaultFailureThreshold
+	}
+	if application.Status.FailureCount < threshold {
+		return ctrl.Result{}, deployErr
+	}
+	reason := fmt.Sprintf("Deployment failed %d times: %v", application.Status.FailureCount, deployErr)
+	if err := r.rollback(application, inputs, revision, reason, log); err != nil {
+		log.Error(err, "Unable to roll back application")
+		return ctrl.Result{}, deployErr
+	}
+	return ctrl.Result{}, nil
+}
+
+// rollback re-applies the latest successful revision rendered from different inputs
+func (r *ApplicationReconciler) rollback(application *federationv1.Application, inputs util.RevisionInputs, failed *util.Revision, reason string, log logr.Logger) error {
+	revisions, err := r.revisions(application).List()
+	if err != nil {
+		return err
+	}
+	inputsHash := inputs.Hash()
+	var target *util.Revision
+	for _, revision := range revisions {
+		if revision.Status == util.RevisionSucceeded && revision.InputsHash != inputsHash {
+			target = revision
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("No successful revision to roll back to")
+	}
+
+	log.Info("Rolling back application", "revision", target.Number, "reason", reason)
+	if err := r.applyRevision(application, target); err != nil {
+		return fmt.Errorf("Unable to re-apply revision %d: %v", target.Number, err)
+	}
+	rollbackStatus := &federationv1.RollbackStatus{
+		Revision:         target.Number,
+		FailedInputsHash: inputsHash,
+		Reason:           reason,
+		RolledBackAt:     metav1.Now(),
+	}
+	if failed != nil {
+		rollbackStatus.FailedRevision = failed.Number
+	}
+	application.Status.Rollback = rollbackStatus
+	application.Status.State = federationv1.RolledBack
+	application.Status.CurrentRevision = target.Number
+	application.Status.FailureCount = 0
+	return nil
+}
+
+// reapplyRevision applies the stored output of a revision without rendering the chart again
+func (r *ApplicationReconciler) reapplyRevision(application *federationv1.Application, number int, state federationv1.ApplicationDeploymentState, log logr.Logger) (ctrl.Result, error) {
+	revisions, err := r.revisions(application).List()
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	for _, revision := range revisions {
+		if revision.Number != number {
+			continue
+		}
+		if err := r.applyRevision(application, revision); err != nil {
+			application.Status.State = federationv1.Errored
+			return ctrl.Result{}, err
+		}
+		application.Status.State = state
+		application.Status.CurrentRevision = revision.Number
+		application.Status.DeployedTimestamp = &metav1.Time{Time: time.Now()}
+		resources, err := util.ParseManifest(&revision.Manifest)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.updateHealth(application, resources, revision, log)
+		return ctrl.Result{}, nil
+	}
+	application.Status.State = federationv1.Errored
+	return ctrl.Result{}, fmt.Errorf("Revision %d no longer exists", number)
+}
+
+// updateHistory prunes revisions beyond the history limit and lists the remaining ones in the status
+func (r *ApplicationReconciler) updateHistory(application *federationv1.Application, log logr.Logger) {
+	if !application.ObjectMeta.DeletionTimestamp.IsZero() {
+		return
+	}
+	revisions, err := r.revisions(application).List()
+	if err != nil {
+		log.Error(err, "Unable to list revisions")
+		return
+	}
+
+	limit := defaultRevisionHistoryLimit
+	if application.Spec.RevisionHistoryLimit != nil {
+		limit = int(*application.Spec.RevisionHistoryLimit)
+	}
+	inUse := []int{application.Status.CurrentRevision}
+	if application.Status.Rollback != nil {
+		inUse = append(inUse, application.Status.Rollback.Revision)
+	}
+	pruned := map[int]bool{}
+	for _, revision := range util.PrunableRevisions(revisions, limit, inUse...) {
+		if err := r.revisions(application).Delete(revision.Number); err != nil {
+			log.Error(err, "Unable to prune revision", "revision", revision.Number)
+			continue
+		}
+		pruned[revision.Number] = true
+	}
+	var kept []*util.Revision
+	for _, revision := range revisions {
+		if !pruned[revision.Number] {
+			kept = append(kept, revision)
+		}
+	}
+
+	application.Status.History = nil
+	for _, revision := range kept {
+		history := federationv1.RevisionHistory{
+			Revision:     revision.Number,
+			ChartVersion: revision.ChartVersion,
+			ValuesHash:   revision.Inputs.ValuesHash(),
+			Status:       string(revision.Status),
+			DeployedAt:   revision.Timestamp,
+		}
+		for _, resource := range revision.Inventory {
+			history.Resources = append(history.Resources, federationv1.ResourceReference(resource))
+		}
+		application.Status.History = append(application.Status.History, history)
+	}
+}
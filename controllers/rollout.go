@@ -0,0 +1,308 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+// defaultStepInterval is the minimum time a rollout step runs when the strategy does not set one
+const defaultStepInterval = time.Minute
+
+// rolloutResources returns the federated resources to apply for the revision. While a rollout is in
+// progress the member clusters not yet updated are pinned to the stable revision.
+func (r *ApplicationReconciler) rolloutResources(application *federationv1.Application, revision *util.Revision) ([]*unstructured.Unstructured, error) {
+	fedResources, err := util.ParseManifest(&revision.FederatedManifest)
+	if err != nil {
+		return nil, err
+	}
+	for _, fedResource := range fedResources {
+		if err := util.SetTemplateRevision(fedResource, revision.Number); err != nil {
+			return nil, err
+		}
+	}
+	strategy := application.Spec.RolloutStrategy
+	if strategy == nil || len(strategy.Steps) == 0 || r.MemberClusters == nil {
+		application.Status.Rollout = nil
+		return fedResources, nil
+	}
+
+	rollout := application.Status.Rollout
+	if rollout == nil || rollout.Revision != revision.Number {
+		stable, err := r.stableRevision(application, revision)
+		if err != nil {
+			return nil, err
+		}
+		if stable == nil || revision.Status != util.RevisionPending {
+			// nothing to roll out progressively on the first deployment
+			application.Status.Rollout = nil
+			return fedResources, nil
+		}
+		clusters, err := r.rolloutClusters(fedResources)
+		if err != nil {
+			return nil, err
+		}
+		rollout = &federationv1.RolloutStatus{
+			Revision:        revision.Number,
+			StableRevision:  stable.Number,
+			Step:            1,
+			UpdatedClusters: clustersForStep(strategy, 1, clusters, nil),
+			StepStartedAt:   &metav1.Time{Time: time.Now()},
+		}
+		application.Status.Rollout = rollout
+	}
+	if rollout.Complete {
+		return fedResources, nil
+	}
+
+	clusters, err := r.rolloutClusters(fedResources)
+	if err != nil {
+		return nil, err
+	}
+	stableResources, err := r.revisionResources(application, rollout.StableRevision)
+	if err != nil {
+		return nil, err
+	}
+	for _, fedResource := range fedResources {
+		// each resource keeps its own placement , the rollout only decides which of its clusters are updated
+		placement, err := util.PlacementClusters([]*unstructured.Unstructured{fedResource}, r.Clusters)
+		if err != nil {
+			return nil, err
+		}
+		pending := intersect(subtract(clusters, rollout.UpdatedClusters), placement)
+		stable, ok := stableResources[resourceKey(fedResource)]
+		if !ok {
+			err = util.RestrictPlacement(fedResource, intersect(rollout.UpdatedClusters, placement))
+		} else if err = util.PinClustersToTemplate(fedResource, stable, pending); err == nil {
+			err = util.PinClustersToRevision(fedResource, pending, rollout.StableRevision)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	rollout.Message = fmt.Sprintf("Step %d of %d: %d of %d clusters updated", rollout.Step, len(strategy.Steps), len(rollout.UpdatedClusters), len(clusters))
+	return fedResources, nil
+}
+
+// advanceRollout moves the rollout to its next step once the updated clusters run the revision and are healthy ,
+// and the step ran for the step interval , returning true when more clusters have to be updated
+func (r *ApplicationReconciler) advanceRollout(application *federationv1.Application, log logr.Logger) (bool, error) {
+	rollout := application.Status.Rollout
+	strategy := application.Spec.RolloutStrategy
+	if rollout == nil || rollout.Complete || strategy == nil {
+		return false, nil
+	}
+	if rollout.StepStartedAt == nil {
+		rollout.StepStartedAt = &metav1.Time{Time: time.Now()}
+	}
+	// the health of the updated clusters includes whether the revision propagated to them
+	if !propagated(application) || application.Status.Health == federationv1.Unknown || !clustersHealthy(application, rollout.UpdatedClusters) {
+		rollout.Message = fmt.Sprintf("Step %d of %d: waiting for updated clusters to become healthy", rollout.Step, len(strategy.Steps))
+		return false, nil
+	}
+	interval := defaultStepInterval
+	if strategy.StepInterval != nil {
+		interval = strategy.StepInterval.Duration
+	}
+	if elapsed := time.Since(rollout.StepStartedAt.Time); elapsed < interval {
+		rollout.Message = fmt.Sprintf("Step %d of %d: updated clusters are healthy , continuing in %s", rollout.Step, len(strategy.Steps), (interval - elapsed).Round(time.Second))
+		return false, nil
+	}
+	if rollout.Step <= len(strategy.Steps) && strategy.Steps[rollout.Step-1].Pause {
+		approved := application.ObjectMeta.Annotations[federationv1.ApproveRolloutStepAnnotation]
+		if approved != strconv.Itoa(rollout.Step) {
+			rollout.Paused = true
+			rollout.Message = fmt.Sprintf("Step %d of %d: paused , set annotation %s to %d to continue", rollout.Step, len(strategy.Steps), federationv1.ApproveRolloutStepAnnotation, rollout.Step)
+			return false, nil
+		}
+	}
+	rollout.Paused = false
+
+	fedResources, err := r.revisionResources(application, rollout.Revision)
+	if err != nil {
+		return false, err
+	}
+	var revisionResources []*unstructured.Unstructured
+	for _, fedResource := range fedResources {
+		revisionResources = append(revisionResources, fedResource)
+	}
+	clusters, err := r.rolloutClusters(revisionResources)
+	if err != nil {
+		return false, err
+	}
+	if rollout.Step >= len(strategy.Steps) || len(subtract(clusters, rollout.UpdatedClusters)) == 0 {
+		rollout.Complete = true
+		rollout.UpdatedClusters = clusters
+		rollout.Message = "Rolled out to all member clusters"
+		return true, nil
+	}
+	rollout.Step++
+	rollout.UpdatedClusters = clustersForStep(strategy, rollout.Step, clusters, rollout.UpdatedClusters)
+	rollout.StepStartedAt = &metav1.Time{Time: time.Now()}
+	log.Info("Advancing rollout", "revision", rollout.Revision, "step", rollout.Step, "clusters", rollout.UpdatedClusters)
+	return true, nil
+}
+
+// rolloutClusters are the member clusters the federated resources of the revision are placed on ,
+// the clusters a rollout of the revision updates
+func (r *ApplicationReconciler) rolloutClusters(fedResources []*unstructured.Unstructured) ([]string, error) {
+	members, err := r.MemberClusters.ClusterNames()
+	if err != nil {
+		return nil, err
+	}
+	placement, err := util.PlacementClusters(fedResources, r.Clusters)
+	if err != nil {
+		return nil, err
+	}
+	return intersect(members, placement), nil
+}
+
+// expectedRevisions maps the placement clusters to the revision they run , the clusters not yet
+// updated by a rollout keep the stable revision
+func expectedRevisions(application *federationv1.Application, revision *util.Revision, placement []string) map[string]int {
+	expected := map[string]int{}
+	for _, cluster := range placement {
+		expected[cluster] = revision.Number
+		if rolloutInProgress(application, revision) && !containsString(application.Status.Rollout.UpdatedClusters, cluster) {
+			expected[cluster] = application.Status.Rollout.StableRevision
+		}
+	}
+	return expected
+}
+
+// rolloutInProgress tells if the revision is still being rolled out to the member clusters
+func rolloutInProgress(application *federationv1.Application, revision *util.Revision) bool {
+	rollout := application.Status.Rollout
+	return rollout != nil && rollout.Revision == revision.Number && !rollout.Complete
+}
+
+// stableRevision is the latest successful revision before the given one
+func (r *ApplicationReconciler) stableRevision(application *federationv1.Application, revision *util.Revision) (*util.Revision, error) {
+	revisions, err := r.revisions(application).List()
+	if err != nil {
+		return nil, err
+	}
+	var stable *util.Revision
+	for _, each := range revisions {
+		if each.Number < revision.Number && each.Status == util.RevisionSucceeded {
+			stable = each
+		}
+	}
+	return stable, nil
+}
+
+// revisionResources returns the federated resources of a revision by kind and name
+func (r *ApplicationReconciler) revisionResources(application *federationv1.Application, number int) (map[string]*unstructured.Unstructured, error) {
+	revisions, err := r.revisions(application).List()
+	if err != nil {
+		return nil, err
+	}
+	for _, revision := range revisions {
+		if revision.Number != number {
+			continue
+		}
+		fedResources, err := util.ParseManifest(&revision.FederatedManifest)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]*unstructured.Unstructured{}
+		for _, fedResource := range fedResources {
+			result[resourceKey(fedResource)] = fedResource
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("Stable revision %d no longer exists", number)
+}
+
+// clustersForStep returns the clusters updated once the given step is done
+func clustersForStep(strategy *federationv1.RolloutStrategy, step int, clusters []string, updated []string) []string {
+	sorted := append([]string(nil), clusters...)
+	sort.Strings(sorted)
+	if step >= len(strategy.Steps) {
+		return sorted
+	}
+	rolloutStep := strategy.Steps[step-1]
+	if len(rolloutStep.Clusters) == 0 && rolloutStep.Percentage == 0 {
+		return sorted
+	}
+
+	result := map[string]bool{}
+	for _, cluster := range updated {
+		result[cluster] = true
+	}
+	for _, cluster := range rolloutStep.Clusters {
+		if containsString(sorted, cluster) {
+			result[cluster] = true
+		}
+	}
+	target := (int(rolloutStep.Percentage)*len(sorted) + 99) / 100
+	for _, cluster := range sorted {
+		if len(result) >= target {
+			break
+		}
+		result[cluster] = true
+	}
+
+	var names []string
+	for _, cluster := range sorted {
+		if result[cluster] {
+			names = append(names, cluster)
+		}
+	}
+	return names
+}
+
+func clustersHealthy(application *federationv1.Application, clusters []string) bool {
+	for _, clusterStatus := range application.Status.Clusters {
+		if containsString(clusters, clusterStatus.Name) && clusterStatus.Health != federationv1.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+func resourceKey(resource *unstructured.Unstructured) string {
+	return resource.GetKind() + "/" + resource.GetName()
+}
+
+func subtract(slice []string, remove []string) (result []string) {
+	for _, item := range slice {
+		if !containsString(remove, item) {
+			result = append(result, item)
+		}
+	}
+	return
+}
+
+func intersect(slice []string, keep []string) (result []string) {
+	for _, item := range slice {
+		if containsString(keep, item) {
+			result = append(result, item)
+		}
+	}
+	return
+}
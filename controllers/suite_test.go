@@ -17,12 +17,16 @@ limitations under the License.
 package controllers
 
 import (
+	"fmt"
 	"path/filepath"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -30,8 +34,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
 
 	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -50,6 +56,25 @@ func TestAPIs(t *testing.T) {
 		[]Reporter{printer.NewlineReporter{}})
 }
 
+// testMemberClusters names member clusters without connecting to them
+type testMemberClusters []string
+
+func (clusters testMemberClusters) ClusterNames() ([]string, error) {
+	return clusters, nil
+}
+
+func (clusters testMemberClusters) DynamicClient(clusterName string) (dynamic.Interface, error) {
+	return nil, fmt.Errorf("Member cluster %s is not reachable from the tests", clusterName)
+}
+
+func (clusters testMemberClusters) MatchingClusters(selector labels.Selector) ([]fedv1b1.KubeFedCluster, error) {
+	var result []fedv1b1.KubeFedCluster
+	for _, name := range clusters {
+		result = append(result, fedv1b1.KubeFedCluster{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return result, nil
+}
+
 var _ = BeforeSuite(func(done Done) {
 	logf.SetLogger(zap.LoggerTo(GinkgoWriter, true))
 	useExistingCluster := true
@@ -74,11 +99,39 @@ var _ = BeforeSuite(func(done Done) {
 		Scheme: scheme.Scheme,
 	})
 	Expect(err).ToNot(HaveOccurred())
-	err = (&ApplicationReconciler{
-		Client: mgr.GetClient(),
-		Config: mgr.GetConfig(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Application"),
-		Scheme: mgr.GetScheme(),
+	applicationReconciler := &ApplicationReconciler{
+		Client:    mgr.GetClient(),
+		Config:    mgr.GetConfig(),
+		Log:       ctrl.Log.WithName("controllers").WithName("Application"),
+		Scheme:    mgr.GetScheme(),
+		Revisions: util.NewSecretRevisionStore(mgr.GetClient(), mgr.GetAPIReader()),
+		// rollouts are planned for member clusters that are not reachable from the tests
+		MemberClusters:              testMemberClusters{"cluster-a", "cluster-b"},
+		Clusters:                    testMemberClusters{"cluster-a", "cluster-b"},
+		ClusterApplicationNamespace: "default",
+	}
+	err = applicationReconciler.SetupWithManager(mgr)
+	Expect(err).ToNot(HaveOccurred())
+	err = (&ClusterApplicationReconciler{
+		ApplicationReconciler: applicationReconciler,
+		Log:                   ctrl.Log.WithName("controllers").WithName("ClusterApplication"),
+	}).SetupWithManager(mgr)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&ApplicationSetReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("ApplicationSet"),
+		Scheme:   mgr.GetScheme(),
+		Clusters: testMemberClusters{"cluster-a", "cluster-b"},
+	}).SetupWithManager(mgr)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = (&HelmRepositoryReconciler{
+		Client:       mgr.GetClient(),
+		Log:          ctrl.Log.WithName("controllers").WithName("HelmRepository"),
+		Scheme:       mgr.GetScheme(),
+		Repositories: federationv1.RepositoryPolicy{Denied: []string{"https://denied.example.com/*"}},
+		APIReader:    mgr.GetAPIReader(),
 	}).SetupWithManager(mgr)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -0,0 +1,197 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
+)
+
+// Capabilities are the Kubernetes version and API versions a chart is rendered against
+type Capabilities struct {
+	KubeVersion string
+	APIVersions []string
+	// member clusters not serving an API version , only known for capabilities of member clusters
+	unavailable map[string][]string
+}
+
+// ExplicitCapabilities returns the given capabilities , adding the API versions to the helm defaults
+// like helm template --api-versions , nil when neither is set
+func ExplicitCapabilities(kubeVersion string, apiVersions []string) *Capabilities {
+	if kubeVersion == "" && len(apiVersions) == 0 {
+		return nil
+	}
+	versions := append([]string(nil), chartutil.DefaultVersionSet...)
+	return &Capabilities{KubeVersion: kubeVersion, APIVersions: append(versions, apiVersions...)}
+}
+
+// MemberClusterCapabilities discovers the lowest Kubernetes version and the API versions served by
+// all member clusters , nil when there are no member clusters
+func MemberClusterCapabilities(clusters ClusterDiscovery) (*Capabilities, error) {
+	return NewCapabilitiesCache(clusters, 0).MemberClusterCapabilities(nil)
+}
+
+// CapabilitiesCache keeps what was discovered about each member cluster for the time to live ,
+// discovering every API group of every cluster on every reconcile is expensive
+type CapabilitiesCache struct {
+	clusters ClusterDiscovery
+	ttl      time.Duration
+	mutex    sync.Mutex
+	entries  map[string]discoveredCluster
+}
+
+// discoveredCluster is the Kubernetes version and the API versions served by a member cluster
+type discoveredCluster struct {
+	version      *semver.Version
+	apiVersions  []string
+	discoveredAt time.Time
+}
+
+// NewCapabilitiesCache creates a cache discovering the member clusters again once the time to live passed ,
+// a time to live of 0 discovers them every time
+func NewCapabilitiesCache(clusters ClusterDiscovery, ttl time.Duration) *CapabilitiesCache {
+	return &CapabilitiesCache{clusters: clusters, ttl: ttl, entries: map[string]discoveredCluster{}}
+}
+
+// MemberClusterCapabilities discovers the lowest Kubernetes version and the API versions served by
+// all the given member clusters , every member cluster when names is empty , nil when there are none
+func (cache *CapabilitiesCache) MemberClusterCapabilities(names []string) (*Capabilities, error) {
+	if len(names) == 0 {
+		all, err := cache.clusters.ClusterNames()
+		if err != nil {
+			return nil, err
+		}
+		names = all
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+
+	var lowest *semver.Version
+	served := map[string][]string{}
+	for _, name := range names {
+		discovered, err := cache.discover(name)
+		if err != nil {
+			return nil, err
+		}
+		if lowest == nil || discovered.version.LessThan(lowest) {
+			lowest = discovered.version
+		}
+		for _, apiVersion := range discovered.apiVersions {
+			served[apiVersion] = append(served[apiVersion], name)
+		}
+	}
+
+	capabilities := &Capabilities{
+		KubeVersion: fmt.Sprintf("v%d.%d.%d", lowest.Major(), lowest.Minor(), lowest.Patch()),
+		unavailable: map[string][]string{},
+	}
+	for apiVersion, servedBy := range served {
+		if len(servedBy) == len(names) {
+			capabilities.APIVersions = append(capabilities.APIVersions, apiVersion)
+			continue
+		}
+		capabilities.unavailable[apiVersion] = subtractStrings(names, servedBy)
+	}
+	sort.Strings(capabilities.APIVersions)
+	return capabilities, nil
+}
+
+// discover returns the cached discovery of the member cluster , discovering it when it expired
+func (cache *CapabilitiesCache) discover(name string) (discoveredCluster, error) {
+	cache.mutex.Lock()
+	cached, ok := cache.entries[name]
+	cache.mutex.Unlock()
+	if ok && time.Since(cached.discoveredAt) < cache.ttl {
+		return cached, nil
+	}
+
+	discoveryClient, err := cache.clusters.DiscoveryClient(name)
+	if err != nil {
+		return discoveredCluster{}, err
+	}
+	serverVersion, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return discoveredCluster{}, fmt.Errorf("Unable to get the Kubernetes version of cluster %s: %v", name, err)
+	}
+	version, err := semver.NewVersion(serverVersion.GitVersion)
+	if err != nil {
+		return discoveredCluster{}, fmt.Errorf("Invalid Kubernetes version %s of cluster %s: %v", serverVersion.GitVersion, name, err)
+	}
+	apiVersions, err := action.GetVersionSet(discoveryClient)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return discoveredCluster{}, fmt.Errorf("Unable to get the API versions of cluster %s: %v", name, err)
+	}
+	discovered := discoveredCluster{version: version, apiVersions: apiVersions, discoveredAt: time.Now()}
+	cache.mutex.Lock()
+	cache.entries[name] = discovered
+	cache.mutex.Unlock()
+	return discovered, nil
+}
+
+// CheckResources fails when a rendered resource uses an API version some member clusters do not serve
+func (capabilities *Capabilities) CheckResources(resources []*unstructured.Unstructured) error {
+	if capabilities == nil {
+		return nil
+	}
+	for _, resource := range resources {
+		apiVersion := resource.GetAPIVersion()
+		clusters, ok := capabilities.unavailable[apiVersion+"/"+resource.GetKind()]
+		if !ok {
+			clusters, ok = capabilities.unavailable[apiVersion]
+		}
+		if ok {
+			return fmt.Errorf("%s %s uses %s which is not served by member clusters %s", resource.GetKind(), resource.GetName(), apiVersion, strings.Join(clusters, ","))
+		}
+	}
+	return nil
+}
+
+// helmCapabilities converts the capabilities , falling back to the helm defaults
+func (capabilities *Capabilities) helmCapabilities() (*chartutil.Capabilities, error) {
+	result := &chartutil.Capabilities{
+		KubeVersion: chartutil.DefaultCapabilities.KubeVersion,
+		APIVersions: chartutil.DefaultVersionSet,
+	}
+	if capabilities.KubeVersion != "" {
+		version, err := semver.NewVersion(capabilities.KubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid Kubernetes version %s: %v", capabilities.KubeVersion, err)
+		}
+		result.KubeVersion = chartutil.KubeVersion{
+			Version: fmt.Sprintf("v%d.%d.%d", version.Major(), version.Minor(), version.Patch()),
+			Major:   fmt.Sprint(version.Major()),
+			Minor:   fmt.Sprint(version.Minor()),
+		}
+	}
+	if len(capabilities.APIVersions) > 0 {
+		result.APIVersions = chartutil.VersionSet(capabilities.APIVersions)
+	}
+	return result, nil
+}
+
+func subtractStrings(slice []string, remove []string) []string {
+	var result []string
+	for _, item := range slice {
+		found := false
+		for _, each := range remove {
+			if each == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,131 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/rest"
+	kubetesting "k8s.io/client-go/testing"
+)
+
+// fakeClusterDiscovery serves in-memory discovery clients per member cluster
+type fakeClusterDiscovery map[string]*fakediscovery.FakeDiscovery
+
+func (clusters fakeClusterDiscovery) ClusterNames() ([]string, error) {
+	var names []string
+	for name := range clusters {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (clusters fakeClusterDiscovery) DiscoveryClient(clusterName string) (discovery.DiscoveryInterface, error) {
+	client, ok := clusters[clusterName]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster %s", clusterName)
+	}
+	return client, nil
+}
+
+func fakeCluster(gitVersion string, groupVersions ...string) *fakediscovery.FakeDiscovery {
+	var resources []*metav1.APIResourceList
+	for _, groupVersion := range groupVersions {
+		resources = append(resources, &metav1.APIResourceList{
+			GroupVersion: groupVersion,
+			APIResources: []metav1.APIResource{{Name: "ingresses", Kind: "Ingress"}},
+		})
+	}
+	return &fakediscovery.FakeDiscovery{
+		Fake:               &kubetesting.Fake{Resources: resources},
+		FakedServerVersion: &version.Info{GitVersion: gitVersion},
+	}
+}
+
+var _ = Describe("capabilities", func() {
+	It("Should use the lowest version and the API versions served by all member clusters", func() {
+		clusters := fakeClusterDiscovery{
+			"cluster-a": fakeCluster("v1.19.2", "networking.k8s.io/v1", "networking.k8s.io/v1beta1"),
+			"cluster-b": fakeCluster("v1.16.8-gke.1", "networking.k8s.io/v1beta1"),
+		}
+		capabilities, err := MemberClusterCapabilities(clusters)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(capabilities.KubeVersion).To(Equal("v1.16.8"))
+		Expect(capabilities.APIVersions).To(ConsistOf("networking.k8s.io/v1beta1", "networking.k8s.io/v1beta1/Ingress"))
+
+		ingress := &unstructured.Unstructured{}
+		ingress.SetAPIVersion("networking.k8s.io/v1")
+		ingress.SetKind("Ingress")
+		ingress.SetName("web")
+		err = capabilities.CheckResources([]*unstructured.Unstructured{ingress})
+		Expect(err).To(MatchError("Ingress web uses networking.k8s.io/v1 which is not served by member clusters cluster-b"))
+
+		ingress.SetAPIVersion("networking.k8s.io/v1beta1")
+		Expect(capabilities.CheckResources([]*unstructured.Unstructured{ingress})).To(Succeed())
+	})
+
+	It("Should only discover the given clusters again once the time to live passed", func() {
+		clusterA := fakeCluster("v1.19.2", "networking.k8s.io/v1")
+		clusterB := fakeCluster("v1.16.8", "networking.k8s.io/v1beta1")
+		cache := NewCapabilitiesCache(fakeClusterDiscovery{"cluster-a": clusterA, "cluster-b": clusterB}, time.Hour)
+
+		capabilities, err := cache.MemberClusterCapabilities([]string{"cluster-a"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(capabilities.KubeVersion).To(Equal("v1.19.2"))
+		Expect(capabilities.APIVersions).To(ContainElement("networking.k8s.io/v1"))
+		discovered := len(clusterA.Actions())
+		Expect(discovered).ToNot(BeZero())
+		Expect(clusterB.Actions()).To(BeEmpty())
+
+		_, err = cache.MemberClusterCapabilities([]string{"cluster-a"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(clusterA.Actions()).To(HaveLen(discovered))
+
+		_, err = NewCapabilitiesCache(fakeClusterDiscovery{"cluster-a": clusterA}, 0).MemberClusterCapabilities([]string{"cluster-a"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(clusterA.Actions())).To(BeNumerically(">", discovered))
+	})
+
+	It("Should add explicit API versions to the helm defaults", func() {
+		Expect(ExplicitCapabilities("", nil)).To(BeNil())
+		capabilities := ExplicitCapabilities("1.18", []string{"networking.k8s.io/v1/Ingress"})
+		Expect(capabilities.APIVersions).To(ContainElement("v1"))
+		Expect(capabilities.APIVersions).To(ContainElement("networking.k8s.io/v1/Ingress"))
+	})
+
+	It("Should render charts against the given capabilities", func() {
+		dir, err := ioutil.TempDir("", "chart")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		Expect(os.MkdirAll(filepath.Join(dir, "templates"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("apiVersion: v2\nname: capabilities\nversion: 0.1.0\n"), 0644)).To(Succeed())
+		template := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+data:
+  minor: "{{ .Capabilities.KubeVersion.Minor }}"
+  ingress: "{{ .Capabilities.APIVersions.Has "networking.k8s.io/v1/Ingress" }}"
+`
+		Expect(ioutil.WriteFile(filepath.Join(dir, "templates", "configmap.yaml"), []byte(template), 0644)).To(Succeed())
+
+		helm, err := NewHelmClient(&rest.Config{})
+		Expect(err).ToNot(HaveOccurred())
+		rendered, err := helm.Render("web", dir, "", GlobalOptions{
+			Namespace:    "apps",
+			Capabilities: ExplicitCapabilities("v1.19.0", []string{"networking.k8s.io/v1/Ingress"}),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rendered.Manifest).To(ContainSubstring(`minor: "19"`))
+		Expect(rendered.Manifest).To(ContainSubstring(`ingress: "true"`))
+	})
+})
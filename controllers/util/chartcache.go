@@ -0,0 +1,178 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// defaultChartCacheTTL is how long a downloaded chart is reused before its repository is asked again
+const defaultChartCacheTTL = 5 * time.Minute
+
+// ChartCache keeps the archives of charts downloaded from repositories , so the webhook and the
+// controller do not download the same chart on every render
+type ChartCache struct {
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[chartCacheKey]chartCacheEntry
+}
+
+// chartCacheKey includes a hash of the repository credentials , so an application is only given
+// charts its own credentials allow to download
+type chartCacheKey struct {
+	repo        string
+	name        string
+	version     string
+	credentials string
+}
+
+type chartCacheEntry struct {
+	path    string
+	fetched time.Time
+}
+
+// sharedCharts is the chart cache of every helm client of the process
+var sharedCharts = NewChartCache(defaultChartCacheTTL)
+
+// NewChartCache creates a chart cache reusing downloads for the given duration
+func NewChartCache(ttl time.Duration) *ChartCache {
+	return &ChartCache{ttl: ttl, entries: map[chartCacheKey]chartCacheEntry{}}
+}
+
+// Load returns a fresh copy of the chart , only charts from a repository are cached since local
+// charts may change at any time
+func (cache *ChartCache) Load(pathOptions action.ChartPathOptions, chartName string, settings *cli.EnvSettings) (*chart.Chart, error) {
+	return cache.load(pathOptions, chartName, func() (string, error) {
+		return pathOptions.LocateChart(chartName, settings)
+	})
+}
+
+// LoadContext is Load cancelling the download of the index and the chart once the context is done
+func (cache *ChartCache) LoadContext(ctx context.Context, pathOptions action.ChartPathOptions, chartName string, settings *cli.EnvSettings) (*chart.Chart, error) {
+	return cache.load(pathOptions, chartName, func() (string, error) {
+		return locateChart(ctx, pathOptions, chartName, settings)
+	})
+}
+
+func (cache *ChartCache) load(pathOptions action.ChartPathOptions, chartName string, locate func() (string, error)) (*chart.Chart, error) {
+	if pathOptions.RepoURL == "" {
+		chartPath, err := locate()
+		if err != nil {
+			return nil, err
+		}
+		return loader.Load(chartPath)
+	}
+
+	key := chartCacheKey{
+		repo:        pathOptions.RepoURL,
+		name:        chartName,
+		version:     pathOptions.Version,
+		credentials: fmt.Sprintf("%x", sha256.Sum256([]byte(pathOptions.Username+"\x00"+pathOptions.Password))),
+	}
+	cache.mutex.Lock()
+	entry, ok := cache.entries[key]
+	cache.mutex.Unlock()
+	if ok && time.Since(entry.fetched) < cache.ttl {
+		// charts are changed while processing their dependencies , so every render loads its own copy
+		if loaded, err := loader.Load(entry.path); err == nil {
+			return loaded, nil
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	chartPath, err := locate()
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	cache.mutex.Lock()
+	cache.entries[key] = chartCacheEntry{path: chartPath, fetched: time.Now()}
+	cache.mutex.Unlock()
+	return loaded, nil
+}
+
+// locateChart downloads the chart of a repository like helm's LocateChart , with http getters cancelling
+// their requests once the context is done
+func locateChart(ctx context.Context, pathOptions action.ChartPathOptions, chartName string, settings *cli.EnvSettings) (string, error) {
+	if pathOptions.RepoURL == "" {
+		return pathOptions.LocateChart(chartName, settings)
+	}
+	getters := contextGetters(ctx, RepositoryCredentials{Username: pathOptions.Username, Password: pathOptions.Password})
+	chartURL, err := repo.FindChartInAuthRepoURL(pathOptions.RepoURL, pathOptions.Username, pathOptions.Password, chartName, pathOptions.Version, "", "", "", getters)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(settings.RepositoryCache, 0755); err != nil {
+		return "", err
+	}
+	chartDownloader := downloader.ChartDownloader{
+		Out:              ioutil.Discard,
+		Getters:          getters,
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
+	}
+	filename, _, err := chartDownloader.DownloadTo(chartURL, pathOptions.Version, settings.RepositoryCache)
+	if err != nil {
+		return "", fmt.Errorf("Unable to download chart %s: %v", chartURL, err)
+	}
+	return filepath.Abs(filename)
+}
+
+// contextGetters serve http and https downloads with the basic auth credentials , like helm sends them
+// with every download of the chart
+func contextGetters(ctx context.Context, credentials RepositoryCredentials) getter.Providers {
+	return getter.Providers{{
+		Schemes: []string{"http", "https"},
+		New: func(options ...getter.Option) (getter.Getter, error) {
+			return &contextGetter{ctx: ctx, credentials: credentials}, nil
+		},
+	}}
+}
+
+// contextGetter is helm's http getter sending its requests with a context
+type contextGetter struct {
+	ctx         context.Context
+	credentials RepositoryCredentials
+}
+
+func (g *contextGetter) Get(href string, options ...getter.Option) (*bytes.Buffer, error) {
+	request, err := http.NewRequest("GET", href, nil)
+	if err != nil {
+		return nil, err
+	}
+	request = request.WithContext(g.ctx)
+	if g.credentials.Username != "" && g.credentials.Password != "" {
+		request.SetBasicAuth(g.credentials.Username, g.credentials.Password)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Failed to fetch %s : %s", href, response.Status)
+	}
+	buf := bytes.NewBuffer(nil)
+	_, err = io.Copy(buf, response.Body)
+	return buf, err
+}
@@ -0,0 +1,123 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+var _ = Describe("chart cache", func() {
+	var (
+		dir       string
+		server    *httptest.Server
+		downloads int32
+		settings  *cli.EnvSettings
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "charts")
+		Expect(err).ToNot(HaveOccurred())
+		repoDir := filepath.Join(dir, "repo")
+		Expect(os.MkdirAll(repoDir, 0755)).To(Succeed())
+
+		downloads = 0
+		files := http.FileServer(http.Dir(repoDir))
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if username, password, ok := r.BasicAuth(); ok && (username != "user" || password != "secret") {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if strings.HasSuffix(r.URL.Path, ".tgz") {
+				atomic.AddInt32(&downloads, 1)
+			}
+			files.ServeHTTP(w, r)
+		}))
+
+		metadata := &chart.Metadata{APIVersion: "v2", Name: "web", Version: "0.1.0"}
+		_, err = chartutil.Save(&chart.Chart{Metadata: metadata}, repoDir)
+		Expect(err).ToNot(HaveOccurred())
+		index := repo.NewIndexFile()
+		index.Add(metadata, "web-0.1.0.tgz", server.URL, "")
+		Expect(index.WriteFile(filepath.Join(repoDir, "index.yaml"), 0644)).To(Succeed())
+
+		settings = &cli.EnvSettings{
+			RepositoryCache:  filepath.Join(dir, "cache"),
+			RepositoryConfig: filepath.Join(dir, "repositories.yaml"),
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("Should reuse downloaded charts until they expire", func() {
+		cache := NewChartCache(time.Hour)
+		pathOptions := action.ChartPathOptions{RepoURL: server.URL, Version: "0.1.0"}
+		first, err := cache.Load(pathOptions, "web", settings)
+		Expect(err).ToNot(HaveOccurred())
+		second, err := cache.Load(pathOptions, "web", settings)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(second.Metadata.Version).To(Equal("0.1.0"))
+		Expect(second).ToNot(BeIdenticalTo(first))
+		Expect(atomic.LoadInt32(&downloads)).To(Equal(int32(1)))
+
+		expired := NewChartCache(0)
+		_, err = expired.Load(pathOptions, "web", settings)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = expired.Load(pathOptions, "web", settings)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(atomic.LoadInt32(&downloads)).To(Equal(int32(3)))
+	})
+
+	It("Should not share charts with applications using other credentials", func() {
+		cache := NewChartCache(time.Hour)
+		authorized := action.ChartPathOptions{RepoURL: server.URL, Version: "0.1.0", Username: "user", Password: "secret"}
+		_, err := cache.Load(authorized, "web", settings)
+		Expect(err).ToNot(HaveOccurred())
+
+		unauthorized := action.ChartPathOptions{RepoURL: server.URL, Version: "0.1.0", Username: "user", Password: "wrong"}
+		_, err = cache.Load(unauthorized, "web", settings)
+		Expect(err).To(HaveOccurred())
+
+		_, err = cache.Load(authorized, "web", settings)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(atomic.LoadInt32(&downloads)).To(Equal(int32(1)))
+	})
+	It("Should cancel downloads once the context is done", func() {
+		cache := NewChartCache(time.Hour)
+		pathOptions := action.ChartPathOptions{RepoURL: server.URL, Version: "0.1.0"}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := cache.LoadContext(ctx, pathOptions, "web", settings)
+		Expect(err).To(MatchError(ContainSubstring("context canceled")))
+
+		loaded, err := cache.LoadContext(context.Background(), pathOptions, "web", settings)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(loaded.Metadata.Version).To(Equal("0.1.0"))
+		Expect(atomic.LoadInt32(&downloads)).To(Equal(int32(1)))
+	})
+
+	It("Should send the credentials with context downloads", func() {
+		cache := NewChartCache(time.Hour)
+		unauthorized := action.ChartPathOptions{RepoURL: server.URL, Version: "0.1.0", Username: "user", Password: "wrong"}
+		_, err := cache.LoadContext(context.Background(), unauthorized, "web", settings)
+		Expect(err).To(HaveOccurred())
+		Expect(atomic.LoadInt32(&downloads)).To(BeZero())
+	})
+})
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+	"sigs.k8s.io/kubefed/pkg/client/generic"
+	ctlutil "sigs.k8s.io/kubefed/pkg/controller/util"
+)
+
+// MemberClusterClient gives access to the member clusters joined to the kubefed control plane
+type MemberClusterClient interface {
+	ClusterNames() ([]string, error)
+	DynamicClient(clusterName string) (dynamic.Interface, error)
+}
+
+// ClusterDiscovery gives access to the discovery API of the member clusters
+type ClusterDiscovery interface {
+	ClusterNames() ([]string, error)
+	DiscoveryClient(clusterName string) (discovery.DiscoveryInterface, error)
+}
+
+// KubeFedMemberClusters resolves member clusters from the KubeFedCluster objects and their secrets
+type KubeFedMemberClusters struct {
+	hostClient       generic.Client
+	kubefedNamespace string
+}
+
+// NewKubeFedMemberClusters creates a member cluster client reading KubeFedClusters from the given namespace
+func NewKubeFedMemberClusters(config *rest.Config, kubefedNamespace string) (*KubeFedMemberClusters, error) {
+	hostClient, err := generic.New(config)
+	if err != nil {
+		return nil, err
+	}
+	if kubefedNamespace == "" {
+		kubefedNamespace = ctlutil.DefaultKubeFedSystemNamespace
+	}
+	return &KubeFedMemberClusters{hostClient: hostClient, kubefedNamespace: kubefedNamespace}, nil
+}
+
+func (clusters *KubeFedMemberClusters) ClusterNames() ([]string, error) {
+	clusterList := &fedv1b1.KubeFedClusterList{}
+	err := clusters.hostClient.List(context.TODO(), clusterList, clusters.kubefedNamespace)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, cluster := range clusterList.Items {
+		names = append(names, cluster.Name)
+	}
+	return names, nil
+}
+
+func (clusters *KubeFedMemberClusters) DynamicClient(clusterName string) (dynamic.Interface, error) {
+	config, err := clusters.ClusterConfig(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return dynamic.NewForConfig(config)
+}
+
+func (clusters *KubeFedMemberClusters) DiscoveryClient(clusterName string) (discovery.DiscoveryInterface, error) {
+	config, err := clusters.ClusterConfig(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	return discovery.NewDiscoveryClientForConfig(config)
+}
+
+// ClusterConfig builds a rest config for the member cluster from its KubeFedCluster secret
+func (clusters *KubeFedMemberClusters) ClusterConfig(clusterName string) (*rest.Config, error) {
+	cluster := &fedv1b1.KubeFedCluster{}
+	err := clusters.hostClient.Get(context.TODO(), cluster, clusters.kubefedNamespace, clusterName)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to fetch kubefed cluster %s: %v", clusterName, err)
+	}
+	return ctlutil.BuildClusterConfig(cluster, clusters.hostClient, clusters.kubefedNamespace)
+}
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ResourceDrift describes how a live federated object differs from the desired one
+type ResourceDrift struct {
+	Resource ResourceReference
+	// The object no longer exists
+	Deleted bool
+	// Paths of the fields that were changed
+	Fields []string
+}
+
+// DetectDrift compares the desired federated resources with the live objects
+func DetectDrift(dynamicClient DynamicClient, desired []*unstructured.Unstructured, namespace string) ([]ResourceDrift, error) {
+	var drifts []ResourceDrift
+	inventory := NewInventory(desired, namespace)
+	for index, resource := range desired {
+		live, err := dynamicClient.Get(*resource, inventory[index].Namespace)
+		if errors.IsNotFound(err) {
+			drifts = append(drifts, ResourceDrift{Resource: inventory[index], Deleted: true})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := DriftedFields(resource, live)
+		if len(fields) > 0 {
+			drifts = append(drifts, ResourceDrift{Resource: inventory[index], Fields: fields})
+		}
+	}
+	return drifts, nil
+}
+
+// DriftedFields returns the paths of the desired spec and labels that do not match the live object
+func DriftedFields(desired *unstructured.Unstructured, live *unstructured.Unstructured) []string {
+	var fields []string
+	desiredLabels, _, _ := unstructured.NestedFieldNoCopy(desired.Object, "metadata", "labels")
+	liveLabels, _, _ := unstructured.NestedFieldNoCopy(live.Object, "metadata", "labels")
+	fields = append(fields, diffSubset(".metadata.labels", desiredLabels, liveLabels)...)
+	fields = append(fields, diffSubset(".spec", desired.Object["spec"], live.Object["spec"])...)
+	sort.Strings(fields)
+	return fields
+}
+
+// diffSubset reports the paths where desired is not contained in live
+func diffSubset(path string, desired interface{}, live interface{}) []string {
+	if desired == nil {
+		return nil
+	}
+	desiredMap, ok := desired.(map[string]interface{})
+	if !ok {
+		if reflect.DeepEqual(desired, live) {
+			return nil
+		}
+		return []string{path}
+	}
+	liveMap, ok := live.(map[string]interface{})
+	if !ok {
+		if len(desiredMap) == 0 && live == nil {
+			return nil
+		}
+		return []string{path}
+	}
+	var fields []string
+	for key, value := range desiredMap {
+		fields = append(fields, diffSubset(strings.Join([]string{path, key}, "."), value, liveMap[key])...)
+	}
+	return fields
+}
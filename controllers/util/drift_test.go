@@ -0,0 +1,62 @@
+package util
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// fakeDynamicClient serves live objects by name
+type fakeDynamicClient map[string]*unstructured.Unstructured
+
+func (client fakeDynamicClient) Apply(resourceObj unstructured.Unstructured, namespace string) error {
+	client[resourceObj.GetName()] = resourceObj.DeepCopy()
+	return nil
+}
+
+func (client fakeDynamicClient) Get(resourceObj unstructured.Unstructured, namespace string) (*unstructured.Unstructured, error) {
+	live, ok := client[resourceObj.GetName()]
+	if !ok {
+		return nil, errors.NewNotFound(schema.GroupResource{Resource: "federatedconfigmaps"}, resourceObj.GetName())
+	}
+	return live, nil
+}
+
+func (client fakeDynamicClient) Delete(resourceObj unstructured.Unstructured, namespace string) error {
+	if _, ok := client[resourceObj.GetName()]; !ok {
+		return errors.NewNotFound(schema.GroupResource{Resource: "federatedconfigmaps"}, resourceObj.GetName())
+	}
+	delete(client, resourceObj.GetName())
+	return nil
+}
+
+var _ = Describe("drift detection", func() {
+	It("Should report changed fields and deleted objects", func() {
+		desired := []*unstructured.Unstructured{
+			federatedConfigMap(map[string]interface{}{"color": "blue"}),
+			federatedConfigMap(nil),
+		}
+		desired[1].SetName("deleted-config")
+
+		live := federatedConfigMap(map[string]interface{}{"color": "red"})
+		live.SetResourceVersion("42")
+		client := fakeDynamicClient{"web-config": live}
+
+		drifts, err := DetectDrift(client, desired, "apps")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(drifts).To(HaveLen(2))
+		Expect(drifts[0].Resource.Namespace).To(Equal("apps"))
+		Expect(drifts[0].Fields).To(Equal([]string{".spec.template.data.color"}))
+		Expect(drifts[1].Deleted).To(BeTrue())
+	})
+
+	It("Should ignore fields that are only present on the live object", func() {
+		desired := federatedConfigMap(map[string]interface{}{"color": "blue"})
+		live := federatedConfigMap(map[string]interface{}{"color": "blue"})
+		live.SetLabels(map[string]string{"kubefed.io/managed": "true"})
+		Expect(unstructured.SetNestedField(live.Object, "extra", "spec", "template", "data", "size")).To(Succeed())
+		Expect(DriftedFields(desired, live)).To(BeEmpty())
+	})
+})
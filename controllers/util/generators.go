@@ -0,0 +1,120 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/labels"
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+)
+
+// Parameters are the values a generator produces for one generated application
+type Parameters map[string]string
+
+// ClusterSelector lists the member clusters matching a label selector
+type ClusterSelector interface {
+	MatchingClusters(selector labels.Selector) ([]fedv1b1.KubeFedCluster, error)
+}
+
+func (clusters *KubeFedMemberClusters) MatchingClusters(selector labels.Selector) ([]fedv1b1.KubeFedCluster, error) {
+	clusterList := &fedv1b1.KubeFedClusterList{}
+	err := clusters.hostClient.List(context.TODO(), clusterList, clusters.kubefedNamespace)
+	if err != nil {
+		return nil, err
+	}
+	var result []fedv1b1.KubeFedCluster
+	for _, cluster := range clusterList.Items {
+		if selector.Matches(labels.Set(cluster.Labels)) {
+			result = append(result, cluster)
+		}
+	}
+	return result, nil
+}
+
+// ClusterParameters are the name , api endpoint and labels of every member cluster , the labels
+// prefixed with labels.
+func ClusterParameters(clusters []fedv1b1.KubeFedCluster) []Parameters {
+	var result []Parameters
+	for _, cluster := range clusters {
+		params := Parameters{
+			"name":        cluster.Name,
+			"apiEndpoint": cluster.Spec.APIEndpoint,
+		}
+		for key, value := range cluster.Labels {
+			params["labels."+key] = value
+		}
+		result = append(result, params)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i]["name"] < result[j]["name"] })
+	return result
+}
+
+// MatrixParameters combines every parameter set of the first generator with every parameter set of the
+// others , failing when generators produce the same parameter with different values
+func MatrixParameters(generated ...[]Parameters) ([]Parameters, error) {
+	if len(generated) == 0 {
+		return nil, nil
+	}
+	result := generated[0]
+	for _, next := range generated[1:] {
+		var combined []Parameters
+		for _, left := range result {
+			for _, right := range next {
+				params := Parameters{}
+				for key, value := range left {
+					params[key] = value
+				}
+				for key, value := range right {
+					if existing, ok := params[key]; ok && existing != value {
+						return nil, fmt.Errorf("Matrix generators disagree on parameter %s: %q and %q", key, existing, value)
+					}
+					params[key] = value
+				}
+				combined = append(combined, params)
+			}
+		}
+		result = combined
+	}
+	return result, nil
+}
+
+var parameterPattern = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// SubstituteParameters replaces every {{parameter}} the generator produced in the strings of the JSON
+// document , other {{ }} text such as helm templates in chart values is kept as is
+func SubstituteParameters(document []byte, params Parameters) ([]byte, error) {
+	var decoded interface{}
+	if err := json.Unmarshal(document, &decoded); err != nil {
+		return nil, err
+	}
+	return json.Marshal(substitute(decoded, params))
+}
+
+func substitute(value interface{}, params Parameters) interface{} {
+	switch typed := value.(type) {
+	case string:
+		return parameterPattern.ReplaceAllStringFunc(typed, func(match string) string {
+			if replacement, ok := params[parameterPattern.FindStringSubmatch(match)[1]]; ok {
+				return replacement
+			}
+			return match
+		})
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(typed))
+		for key, each := range typed {
+			result[substitute(key, params).(string)] = substitute(each, params)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(typed))
+		for i, each := range typed {
+			result[i] = substitute(each, params)
+		}
+		return result
+	default:
+		return value
+	}
+}
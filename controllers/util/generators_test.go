@@ -0,0 +1,58 @@
+package util
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+)
+
+var _ = Describe("generators", func() {
+	It("Should generate the parameters of every member cluster", func() {
+		params := ClusterParameters([]fedv1b1.KubeFedCluster{
+			{ObjectMeta: metav1.ObjectMeta{Name: "eu-2", Labels: map[string]string{"region": "eu"}}, Spec: fedv1b1.KubeFedClusterSpec{APIEndpoint: "https://eu-2"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "eu-1"}, Spec: fedv1b1.KubeFedClusterSpec{APIEndpoint: "https://eu-1"}},
+		})
+		Expect(params).To(Equal([]Parameters{
+			{"name": "eu-1", "apiEndpoint": "https://eu-1"},
+			{"name": "eu-2", "apiEndpoint": "https://eu-2", "labels.region": "eu"},
+		}))
+	})
+
+	It("Should combine every parameter set of a matrix", func() {
+		params, err := MatrixParameters(
+			[]Parameters{{"environment": "staging"}, {"environment": "production"}},
+			[]Parameters{{"name": "eu-1"}, {"name": "us-1"}},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(params).To(Equal([]Parameters{
+			{"environment": "staging", "name": "eu-1"},
+			{"environment": "staging", "name": "us-1"},
+			{"environment": "production", "name": "eu-1"},
+			{"environment": "production", "name": "us-1"},
+		}))
+	})
+
+	It("Should fail when matrix generators disagree on a parameter", func() {
+		_, err := MatrixParameters([]Parameters{{"name": "a"}}, []Parameters{{"name": "b"}})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should substitute parameters in keys and values", func() {
+		substituted, err := SubstituteParameters(
+			[]byte(`{"name":"web-{{ name }}","values":{"{{environment}}":true,"hosts":["{{name}}.example.com"],"replicas":2}}`),
+			Parameters{"name": "eu-1", "environment": "staging"},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(substituted).To(MatchJSON(`{"name":"web-eu-1","values":{"staging":true,"hosts":["eu-1.example.com"],"replicas":2}}`))
+	})
+
+	It("Should keep template text of unknown parameters", func() {
+		substituted, err := SubstituteParameters(
+			[]byte(`{"name":"web-{{name}}","values":{"fullname":"{{ .Release.Name }}-{{name}}","host":"{{ include \"host\" . }}","other":"{{cluster}}"}}`),
+			Parameters{"name": "eu-1"},
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(substituted).To(MatchJSON(`{"name":"web-eu-1","values":{"fullname":"{{ .Release.Name }}-eu-1","host":"{{ include \"host\" . }}","other":"{{cluster}}"}}`))
+	})
+})
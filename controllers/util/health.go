@@ -0,0 +1,218 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Health of a workload or a member cluster , ordered from best to worst
+type Health int
+
+const (
+	Healthy Health = iota
+	Progressing
+	Unknown
+	Degraded
+)
+
+func (health Health) String() string {
+	switch health {
+	case Healthy:
+		return "Healthy"
+	case Progressing:
+		return "Progressing"
+	case Degraded:
+		return "Degraded"
+	}
+	return "Unknown"
+}
+
+// ClusterHealth is the aggregated health of the rendered workloads in one member cluster
+type ClusterHealth struct {
+	Cluster string
+	Health  Health
+	Message string
+}
+
+// HealthChecker evaluates the rendered workloads against the member clusters they are placed on ,
+// clusters maps each cluster to the revision its workloads have to be applied from , 0 accepts any revision
+type HealthChecker interface {
+	CheckHealth(resources []*unstructured.Unstructured, namespace string, clusters map[string]int) ([]ClusterHealth, error)
+}
+
+// WorkloadHealthChecker checks Deployments, StatefulSets, DaemonSets and Jobs in the member clusters
+type WorkloadHealthChecker struct {
+	clusters MemberClusterClient
+}
+
+// NewWorkloadHealthChecker creates a health checker for the given member clusters
+func NewWorkloadHealthChecker(clusters MemberClusterClient) *WorkloadHealthChecker {
+	return &WorkloadHealthChecker{clusters: clusters}
+}
+
+func (checker *WorkloadHealthChecker) CheckHealth(resources []*unstructured.Unstructured, namespace string, clusters map[string]int) ([]ClusterHealth, error) {
+	var clusterNames []string
+	for clusterName := range clusters {
+		clusterNames = append(clusterNames, clusterName)
+	}
+	sort.Strings(clusterNames)
+
+	var result []ClusterHealth
+	for _, clusterName := range clusterNames {
+		result = append(result, checker.checkCluster(clusterName, resources, namespace, clusters[clusterName]))
+	}
+	return result, nil
+}
+
+func (checker *WorkloadHealthChecker) checkCluster(clusterName string, resources []*unstructured.Unstructured, namespace string, revision int) ClusterHealth {
+	clusterHealth := ClusterHealth{Cluster: clusterName, Health: Healthy}
+	dynamicClient, err := checker.clusters.DynamicClient(clusterName)
+	if err != nil {
+		return ClusterHealth{Cluster: clusterName, Health: Unknown, Message: err.Error()}
+	}
+	for _, resource := range resources {
+		if !isWorkload(resource) {
+			continue
+		}
+		gvk := resource.GroupVersionKind()
+		gvr, _ := apimeta.UnsafeGuessKindToResource(gvk)
+		resourceNamespace := resource.GetNamespace()
+		if resourceNamespace == "" {
+			resourceNamespace = namespace
+		}
+
+		var health Health
+		var message string
+		live, err := dynamicClient.Resource(gvr).Namespace(resourceNamespace).Get(resource.GetName(), metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			health, message = Progressing, "not yet propagated"
+		case err != nil:
+			health, message = Unknown, err.Error()
+		case !appliedFrom(live, revision):
+			// kubefed has not propagated the revision yet , the status is the one of the previous revision
+			health, message = Progressing, fmt.Sprintf("waiting for revision %d to propagate", revision)
+		default:
+			health, message = WorkloadHealth(live)
+		}
+		if health > clusterHealth.Health {
+			clusterHealth.Health = health
+			clusterHealth.Message = fmt.Sprintf("%s %s: %s", gvk.Kind, resource.GetName(), message)
+		}
+	}
+	return clusterHealth
+}
+
+// FederatedPropagation tells if kubefed propagated the latest generation of the federated object to
+// the member clusters , with the reason when it did not yet
+func FederatedPropagation(live *unstructured.Unstructured) (bool, string) {
+	observedGeneration, _, _ := unstructured.NestedInt64(live.Object, "status", "observedGeneration")
+	if observedGeneration < live.GetGeneration() {
+		return false, "waiting for kubefed to observe the latest generation"
+	}
+	conditions, _, _ := unstructured.NestedSlice(live.Object, "status", "conditions")
+	for _, each := range conditions {
+		condition, ok := each.(map[string]interface{})
+		if !ok || condition["type"] != "Propagation" {
+			continue
+		}
+		if condition["status"] == "True" {
+			return true, ""
+		}
+		reason, _ := condition["reason"].(string)
+		return false, fmt.Sprintf("propagation failed: %s", reason)
+	}
+	return false, "waiting for kubefed to propagate"
+}
+
+// appliedFrom tells if the live object was propagated from the revision
+func appliedFrom(live *unstructured.Unstructured, revision int) bool {
+	return revision == 0 || live.GetAnnotations()[RevisionAnnotation] == strconv.Itoa(revision)
+}
+
+func isWorkload(resource *unstructured.Unstructured) bool {
+	switch resource.GetKind() {
+	case "Deployment", "StatefulSet", "DaemonSet", "Job":
+		return true
+	}
+	return false
+}
+
+// WorkloadHealth evaluates the status of a single live workload object
+func WorkloadHealth(live *unstructured.Unstructured) (Health, string) {
+	generation := live.GetGeneration()
+	observedGeneration, _, _ := unstructured.NestedInt64(live.Object, "status", "observedGeneration")
+	if live.GetKind() != "Job" && observedGeneration < generation {
+		return Progressing, "waiting for the controller to observe the latest generation"
+	}
+
+	switch live.GetKind() {
+	case "Deployment":
+		if hasCondition(live, "Progressing", "False") {
+			return Degraded, "progress deadline exceeded"
+		}
+		replicas := specReplicas(live)
+		updated := statusInt(live, "updatedReplicas")
+		available := statusInt(live, "availableReplicas")
+		total := statusInt(live, "replicas")
+		if updated < replicas || total > updated || available < updated {
+			return Progressing, fmt.Sprintf("%d of %d updated replicas available", available, replicas)
+		}
+	case "StatefulSet":
+		replicas := specReplicas(live)
+		ready := statusInt(live, "readyReplicas")
+		currentRevision, _, _ := unstructured.NestedString(live.Object, "status", "currentRevision")
+		updateRevision, _, _ := unstructured.NestedString(live.Object, "status", "updateRevision")
+		if ready < replicas || currentRevision != updateRevision {
+			return Progressing, fmt.Sprintf("%d of %d replicas ready", ready, replicas)
+		}
+	case "DaemonSet":
+		desired := statusInt(live, "desiredNumberScheduled")
+		updated := statusInt(live, "updatedNumberScheduled")
+		available := statusInt(live, "numberAvailable")
+		if updated < desired || available < desired {
+			return Progressing, fmt.Sprintf("%d of %d pods available", available, desired)
+		}
+	case "Job":
+		if hasCondition(live, "Failed", "True") {
+			return Degraded, "job failed"
+		}
+		if !hasCondition(live, "Complete", "True") {
+			return Progressing, "job has not completed"
+		}
+	}
+	return Healthy, ""
+}
+
+func specReplicas(live *unstructured.Unstructured) int64 {
+	replicas, found, _ := unstructured.NestedInt64(live.Object, "spec", "replicas")
+	if !found {
+		return 1
+	}
+	return replicas
+}
+
+func statusInt(live *unstructured.Unstructured, field string) int64 {
+	value, _, _ := unstructured.NestedInt64(live.Object, "status", field)
+	return value
+}
+
+func hasCondition(live *unstructured.Unstructured, conditionType string, status string) bool {
+	conditions, _, _ := unstructured.NestedSlice(live.Object, "status", "conditions")
+	for _, each := range conditions {
+		condition, ok := each.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == conditionType && condition["status"] == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package util
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+// fakeMemberClusters serves in-memory dynamic clients per member cluster
+type fakeMemberClusters map[string]dynamic.Interface
+
+func (clusters fakeMemberClusters) ClusterNames() ([]string, error) {
+	var names []string
+	for name := range clusters {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (clusters fakeMemberClusters) DynamicClient(clusterName string) (dynamic.Interface, error) {
+	client, ok := clusters[clusterName]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster %s", clusterName)
+	}
+	return client, nil
+}
+
+func deployment(name string, replicas, available int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":       name,
+			"namespace":  "apps",
+			"generation": int64(1),
+		},
+		"spec": map[string]interface{}{"replicas": replicas},
+		"status": map[string]interface{}{
+			"observedGeneration": int64(1),
+			"replicas":           replicas,
+			"updatedReplicas":    replicas,
+			"availableReplicas":  available,
+		},
+	}}
+}
+
+var _ = Describe("workload health checker", func() {
+	rendered := []*unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web"},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   map[string]interface{}{"name": "web-config"},
+		}},
+	}
+
+	It("Should report the health of each member cluster", func() {
+		clusters := fakeMemberClusters{
+			"cluster-a": fake.NewSimpleDynamicClient(runtime.NewScheme(), deployment("web", 2, 2)),
+			"cluster-b": fake.NewSimpleDynamicClient(runtime.NewScheme(), deployment("web", 2, 1)),
+			"cluster-c": fake.NewSimpleDynamicClient(runtime.NewScheme()),
+		}
+		result, err := NewWorkloadHealthChecker(clusters).CheckHealth(rendered, "apps", map[string]int{"cluster-c": 0, "cluster-b": 0, "cluster-a": 0})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(HaveLen(3))
+
+		Expect(result[0].Cluster).To(Equal("cluster-a"))
+		Expect(result[0].Health).To(Equal(Healthy))
+		Expect(result[1].Cluster).To(Equal("cluster-b"))
+		Expect(result[1].Health).To(Equal(Progressing))
+		Expect(result[1].Message).To(ContainSubstring("Deployment web"))
+		Expect(result[2].Cluster).To(Equal("cluster-c"))
+		Expect(result[2].Health).To(Equal(Progressing))
+		Expect(result[2].Message).To(ContainSubstring("not yet propagated"))
+	})
+
+	It("Should only check the clusters of the placement", func() {
+		clusters := fakeMemberClusters{
+			"cluster-a": fake.NewSimpleDynamicClient(runtime.NewScheme(), deployment("web", 2, 2)),
+			"cluster-b": fake.NewSimpleDynamicClient(runtime.NewScheme()),
+		}
+		result, err := NewWorkloadHealthChecker(clusters).CheckHealth(rendered, "apps", map[string]int{"cluster-a": 0})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal([]ClusterHealth{{Cluster: "cluster-a", Health: Healthy}}))
+	})
+
+	It("Should wait for the revision to propagate", func() {
+		stale := deployment("web", 2, 2)
+		stale.SetAnnotations(map[string]string{RevisionAnnotation: "1"})
+		propagated := deployment("web", 2, 2)
+		propagated.SetAnnotations(map[string]string{RevisionAnnotation: "2"})
+		clusters := fakeMemberClusters{
+			"cluster-a": fake.NewSimpleDynamicClient(runtime.NewScheme(), propagated),
+			"cluster-b": fake.NewSimpleDynamicClient(runtime.NewScheme(), stale),
+			"cluster-c": fake.NewSimpleDynamicClient(runtime.NewScheme(), stale.DeepCopy()),
+		}
+		result, err := NewWorkloadHealthChecker(clusters).CheckHealth(rendered, "apps", map[string]int{"cluster-a": 2, "cluster-b": 2, "cluster-c": 1})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(HaveLen(3))
+		Expect(result[0].Health).To(Equal(Healthy))
+		Expect(result[1].Health).To(Equal(Progressing))
+		Expect(result[1].Message).To(ContainSubstring("waiting for revision 2 to propagate"))
+		Expect(result[2].Health).To(Equal(Healthy))
+	})
+
+	It("Should wait for kubefed to propagate the latest generation", func() {
+		federated := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "types.kubefed.io/v1beta1",
+			"kind":       "FederatedDeployment",
+			"metadata":   map[string]interface{}{"name": "web", "generation": int64(2)},
+			"status": map[string]interface{}{
+				"observedGeneration": int64(1),
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Propagation", "status": "True"},
+				},
+			},
+		}}
+		propagated, message := FederatedPropagation(federated)
+		Expect(propagated).To(BeFalse())
+		Expect(message).To(ContainSubstring("latest generation"))
+
+		Expect(unstructured.SetNestedField(federated.Object, int64(2), "status", "observedGeneration")).To(Succeed())
+		propagated, _ = FederatedPropagation(federated)
+		Expect(propagated).To(BeTrue())
+
+		Expect(unstructured.SetNestedSlice(federated.Object, []interface{}{
+			map[string]interface{}{"type": "Propagation", "status": "False", "reason": "CheckClusters"},
+		}, "status", "conditions")).To(Succeed())
+		propagated, message = FederatedPropagation(federated)
+		Expect(propagated).To(BeFalse())
+		Expect(message).To(Equal("propagation failed: CheckClusters"))
+	})
+
+	It("Should mark failed jobs as degraded", func() {
+		job := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "batch/v1",
+			"kind":       "Job",
+			"metadata":   map[string]interface{}{"name": "migrate"},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Failed", "status": "True"},
+				},
+			},
+		}}
+		health, _ := WorkloadHealth(job)
+		Expect(health).To(Equal(Degraded))
+	})
+})
@@ -1,21 +1,34 @@
 package util
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
 
 	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/cli/values"
 	"helm.sh/helm/v3/pkg/getter"
-	"k8s.io/cli-runtime/pkg/genericclioptions"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
 )
 
 // HelmClient interface
 type HelmClient interface {
 	Template(releaseName string, chartName string, chartRepo string, options GlobalOptions) (*string, error)
+	Render(releaseName string, chartName string, chartRepo string, options GlobalOptions) (*RenderedChart, error)
+	ResolveChart(ctx context.Context, chartName string, chartRepo string, version string, credentials RepositoryCredentials) (string, error)
+}
+
+// RenderedChart is the output of rendering a chart along with the chart it was rendered from
+type RenderedChart struct {
+	Manifest     string
+	ChartVersion string
 }
 
 // Helm properties struct
@@ -24,6 +37,16 @@ type Helm struct {
 }
 type GlobalOptions struct {
 	Namespace string
+	// Chart version to render , the latest version when empty
+	Version string
+	// Values overriding the chart defaults
+	Values map[string]interface{}
+	// Local values files , overriding Values
+	ValueFiles []string
+	// Capabilities to render against , the helm defaults when nil
+	Capabilities *Capabilities
+	// Basic auth credentials of the chart repository
+	Credentials RepositoryCredentials
 }
 
 // NewHelmClient creates and intializes a helmclient
@@ -34,6 +57,14 @@ func NewHelmClient(kubeconfig *rest.Config) (HelmClient, error) {
 }
 
 func (helm *Helm) Template(releaseName string, chartName string, chartRepo string, options GlobalOptions) (*string, error) {
+	rendered, err := helm.Render(releaseName, chartName, chartRepo, options)
+	if err != nil {
+		return nil, err
+	}
+	return &rendered.Manifest, nil
+}
+
+func (helm *Helm) Render(releaseName string, chartName string, chartRepo string, options GlobalOptions) (*RenderedChart, error) {
 	config, err := helm.createConfig(options)
 	if err != nil {
 		return nil, err
@@ -45,53 +76,99 @@ func (helm *Helm) Template(releaseName string, chartName string, chartRepo strin
 	installer.ReleaseName = releaseName
 	installer.Namespace = options.Namespace
 	installer.RepoURL = chartRepo
-	//configFlags := helm.getConfigFlags(options.namespace)
+	installer.Version = options.Version
+	installer.Username = options.Credentials.Username
+	installer.Password = options.Credentials.Password
+	if options.Capabilities != nil {
+		// client only rendering always uses the default capabilities , so render a dry run against
+		// the given capabilities without any cluster access instead
+		capabilities, err := options.Capabilities.helmCapabilities()
+		if err != nil {
+			return nil, err
+		}
+		config.Capabilities = capabilities
+		config.KubeClient = &kubefake.PrintingKubeClient{Out: ioutil.Discard}
+		releases := driver.NewMemory()
+		releases.SetNamespace(options.Namespace)
+		config.Releases = storage.Init(releases)
+		installer.ClientOnly = false
+	}
 
 	settings := cli.New()
-	chartPath, err := installer.ChartPathOptions.LocateChart(chartName, settings)
-	if err != nil {
-		return nil, err
-	}
-	//TODO merge values
-	chart, err := loader.Load(chartPath)
+	chart, err := loadChart(installer.ChartPathOptions, chartName, settings)
 	if err != nil {
 		return nil, err
 	}
 
 	p := getter.All(settings)
-	valueOpts := values.Options{}
-	vals, err := valueOpts.MergeValues(p)
+	valueOpts := values.Options{ValueFiles: options.ValueFiles}
+	fileVals, err := valueOpts.MergeValues(p)
 	if err != nil {
 		return nil, err
 	}
+	vals := mergeValues(runtime.DeepCopyJSON(options.Values), fileVals)
+	if err := ValidateValues(chart, vals); err != nil {
+		return nil, err
+	}
 	rel, err := installer.Run(chart, vals)
 	if err != nil {
 		return nil, err
 	}
-	return &rel.Manifest, nil
+	return &RenderedChart{Manifest: rel.Manifest, ChartVersion: chart.Metadata.Version}, nil
+}
+
+// ResolveChart fetches the chart from the repository and returns the version it resolves to , the download
+// is cancelled once the context is done
+func (helm *Helm) ResolveChart(ctx context.Context, chartName string, chartRepo string, version string, credentials RepositoryCredentials) (string, error) {
+	pathOptions := action.ChartPathOptions{
+		RepoURL:  chartRepo,
+		Version:  version,
+		Username: credentials.Username,
+		Password: credentials.Password,
+	}
+	chart, err := sharedCharts.LoadContext(ctx, pathOptions, chartName, cli.New())
+	if err != nil {
+		return "", err
+	}
+	return chart.Metadata.Version, nil
+}
 
+func loadChart(pathOptions action.ChartPathOptions, chartName string, settings *cli.EnvSettings) (*chart.Chart, error) {
+	return sharedCharts.Load(pathOptions, chartName, settings)
 }
 
 func (helm *Helm) createConfig(options GlobalOptions) (*action.Configuration, error) {
-	configFlags := helm.getConfigFlags(options.Namespace)
+	getter, err := NewRESTClientGetter(helm.kubeconfig)
+	if err != nil {
+		return nil, err
+	}
 	actionConfig := new(action.Configuration)
-	err := actionConfig.Init(configFlags, options.Namespace, "", debugLog)
+	err = actionConfig.Init(getter.WithNamespace(options.Namespace), options.Namespace, "", debugLog)
 	return actionConfig, err
 }
-func (helm *Helm) getConfigFlags(namespace string) *genericclioptions.ConfigFlags {
-	return &genericclioptions.ConfigFlags{
-		Namespace:   &namespace,
-		APIServer:   &helm.kubeconfig.Host,
-		CAFile:      &helm.kubeconfig.CAFile,
-		BearerToken: &helm.kubeconfig.BearerToken,
-	}
-}
 
 func debugLog(format string, v ...interface{}) {
 	format = fmt.Sprintf("[debug] %s\n", format)
 	log.Output(2, fmt.Sprintf(format, v...))
 }
 
+// mergeValues overlays override onto base , merging nested maps
+func mergeValues(base, override map[string]interface{}) map[string]interface{} {
+	if base == nil {
+		base = map[string]interface{}{}
+	}
+	for key, value := range override {
+		if overrideMap, ok := value.(map[string]interface{}); ok {
+			if baseMap, ok := base[key].(map[string]interface{}); ok {
+				base[key] = mergeValues(baseMap, overrideMap)
+				continue
+			}
+		}
+		base[key] = value
+	}
+	return base
+}
+
 func DerefString(s *string) string {
 	if s != nil {
 		return *s
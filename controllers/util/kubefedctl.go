@@ -61,6 +61,11 @@ func (federatedResource *FederatedResource) GenerateFederatedManifest(input *str
 	return &result, nil
 }
 
+// ParseManifest splits a rendered manifest into its unstructured objects
+func ParseManifest(input *string) ([]*unstructured.Unstructured, error) {
+	return parseInputResources(input)
+}
+
 func parseInputResources(input *string) ([]*unstructured.Unstructured, error) {
 	var unstructuredList []*unstructured.Unstructured
 	reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(*input)))
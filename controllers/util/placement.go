@@ -0,0 +1,188 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// PinClustersToTemplate adds cluster overrides to the federated resource so the given clusters keep
+// receiving the template of the stable federated resource
+func PinClustersToTemplate(fedResource *unstructured.Unstructured, stable *unstructured.Unstructured, clusters []string) error {
+	template, _, err := unstructured.NestedMap(fedResource.Object, "spec", "template")
+	if err != nil {
+		return err
+	}
+	stableTemplate, _, err := unstructured.NestedMap(stable.Object, "spec", "template")
+	if err != nil {
+		return err
+	}
+
+	var fields []string
+	for field := range template {
+		fields = append(fields, field)
+	}
+	for field := range stableTemplate {
+		if _, ok := template[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var clusterOverrides []interface{}
+	for _, field := range fields {
+		switch field {
+		case "apiVersion", "kind", "metadata":
+			continue
+		}
+		stableValue, inStable := stableTemplate[field]
+		_, inTemplate := template[field]
+		override := map[string]interface{}{"path": fmt.Sprintf("/%s", field)}
+		switch {
+		case inStable && inTemplate:
+			override["value"] = stableValue
+		case inStable:
+			override["op"] = "add"
+			override["value"] = stableValue
+		default:
+			override["op"] = "remove"
+		}
+		clusterOverrides = append(clusterOverrides, override)
+	}
+	if len(clusterOverrides) == 0 {
+		return nil
+	}
+	return addClusterOverrides(fedResource, clusters, clusterOverrides)
+}
+
+// SetTemplateRevision annotates the template of the federated resource with the revision it is applied from ,
+// the annotation shows which revision the member clusters received
+func SetTemplateRevision(fedResource *unstructured.Unstructured, revision int) error {
+	annotations, _, err := unstructured.NestedStringMap(fedResource.Object, "spec", "template", "metadata", "annotations")
+	if err != nil {
+		return err
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[RevisionAnnotation] = strconv.Itoa(revision)
+	return unstructured.SetNestedStringMap(fedResource.Object, annotations, "spec", "template", "metadata", "annotations")
+}
+
+// TemplateRevision is the revision the template of a live federated object was applied from , 0 when
+// it was not applied by a revision
+func TemplateRevision(fedResource *unstructured.Unstructured) int {
+	annotations, _, _ := unstructured.NestedStringMap(fedResource.Object, "spec", "template", "metadata", "annotations")
+	revision, err := strconv.Atoi(annotations[RevisionAnnotation])
+	if err != nil {
+		return 0
+	}
+	return revision
+}
+
+// PinClustersToRevision overrides the revision annotation of the template for the given clusters ,
+// which are pinned to the template of an earlier revision
+func PinClustersToRevision(fedResource *unstructured.Unstructured, clusters []string, revision int) error {
+	path := "/metadata/annotations/" + strings.Replace(strings.Replace(RevisionAnnotation, "~", "~0", -1), "/", "~1", -1)
+	return addClusterOverrides(fedResource, clusters, []interface{}{
+		map[string]interface{}{"path": path, "value": strconv.Itoa(revision)},
+	})
+}
+
+// addClusterOverrides appends the overrides to the existing overrides of each cluster , kubefed expects
+// a single entry per cluster
+func addClusterOverrides(fedResource *unstructured.Unstructured, clusters []string, clusterOverrides []interface{}) error {
+	overrides, _, err := unstructured.NestedSlice(fedResource.Object, "spec", "overrides")
+	if err != nil {
+		return err
+	}
+	for _, cluster := range clusters {
+		merged := false
+		for _, each := range overrides {
+			override, ok := each.(map[string]interface{})
+			if !ok || override["clusterName"] != cluster {
+				continue
+			}
+			existing, _ := override["clusterOverrides"].([]interface{})
+			override["clusterOverrides"] = append(existing, runtime.DeepCopyJSONValue(clusterOverrides).([]interface{})...)
+			merged = true
+			break
+		}
+		if !merged {
+			overrides = append(overrides, map[string]interface{}{
+				"clusterName":      cluster,
+				"clusterOverrides": runtime.DeepCopyJSONValue(clusterOverrides),
+			})
+		}
+	}
+	return unstructured.SetNestedSlice(fedResource.Object, overrides, "spec", "overrides")
+}
+
+// RestrictPlacement places the federated resource on the given clusters only
+func RestrictPlacement(fedResource *unstructured.Unstructured, clusters []string) error {
+	placement := []interface{}{}
+	for _, cluster := range clusters {
+		placement = append(placement, map[string]interface{}{"name": cluster})
+	}
+	unstructured.RemoveNestedField(fedResource.Object, "spec", "placement", "clusterSelector")
+	return unstructured.SetNestedSlice(fedResource.Object, placement, "spec", "placement", "clusters")
+}
+
+// PlacementClusters resolves the member clusters the federated resources are placed on , the named
+// clusters of each resource or else the clusters matching its cluster selector
+func PlacementClusters(fedResources []*unstructured.Unstructured, clusters ClusterSelector) ([]string, error) {
+	placed := map[string]bool{}
+	for _, fedResource := range fedResources {
+		named, found, err := unstructured.NestedSlice(fedResource.Object, "spec", "placement", "clusters")
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			for _, each := range named {
+				if cluster, ok := each.(map[string]interface{}); ok {
+					if name, _ := cluster["name"].(string); name != "" {
+						placed[name] = true
+					}
+				}
+			}
+			continue
+		}
+		selectorMap, found, err := unstructured.NestedMap(fedResource.Object, "spec", "placement", "clusterSelector")
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			// kubefed places resources without clusters and cluster selector nowhere
+			continue
+		}
+		var labelSelector metav1.LabelSelector
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(selectorMap, &labelSelector); err != nil {
+			return nil, fmt.Errorf("Invalid cluster selector of %s %s: %v", fedResource.GetKind(), fedResource.GetName(), err)
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid cluster selector of %s %s: %v", fedResource.GetKind(), fedResource.GetName(), err)
+		}
+		if clusters == nil {
+			return nil, fmt.Errorf("Unable to resolve the cluster selector of %s %s without member clusters", fedResource.GetKind(), fedResource.GetName())
+		}
+		matching, err := clusters.MatchingClusters(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, cluster := range matching {
+			placed[cluster.Name] = true
+		}
+	}
+	var names []string
+	for name := range placed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	fedv1b1 "sigs.k8s.io/kubefed/pkg/apis/core/v1beta1"
+)
+
+// fakeClusterSelector matches selectors against the labels of in-memory member clusters
+type fakeClusterSelector []fedv1b1.KubeFedCluster
+
+func (clusters fakeClusterSelector) MatchingClusters(selector labels.Selector) ([]fedv1b1.KubeFedCluster, error) {
+	var result []fedv1b1.KubeFedCluster
+	for _, cluster := range clusters {
+		if selector.Matches(labels.Set(cluster.Labels)) {
+			result = append(result, cluster)
+		}
+	}
+	return result, nil
+}
+
+func federatedConfigMap(data map[string]interface{}) *unstructured.Unstructured {
+	template := map[string]interface{}{}
+	if data != nil {
+		template["data"] = data
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "types.kubefed.io/v1beta1",
+		"kind":       "FederatedConfigMap",
+		"metadata":   map[string]interface{}{"name": "web-config"},
+		"spec": map[string]interface{}{
+			"template": template,
+			"placement": map[string]interface{}{
+				"clusterSelector": map[string]interface{}{"matchLabels": map[string]interface{}{}},
+			},
+		},
+	}}
+}
+
+var _ = Describe("rollout placement", func() {
+	It("Should pin pending clusters to the stable template", func() {
+		fedResource := federatedConfigMap(map[string]interface{}{"color": "blue"})
+		stable := federatedConfigMap(map[string]interface{}{"color": "green"})
+		Expect(PinClustersToTemplate(fedResource, stable, []string{"cluster-b", "cluster-c"})).To(Succeed())
+
+		overrides, _, err := unstructured.NestedSlice(fedResource.Object, "spec", "overrides")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(overrides).To(HaveLen(2))
+		Expect(overrides[0]).To(Equal(map[string]interface{}{
+			"clusterName": "cluster-b",
+			"clusterOverrides": []interface{}{
+				map[string]interface{}{"path": "/data", "value": map[string]interface{}{"color": "green"}},
+			},
+		}))
+	})
+
+	It("Should remove fields the stable template does not have", func() {
+		fedResource := federatedConfigMap(map[string]interface{}{"color": "blue"})
+		stable := federatedConfigMap(nil)
+		Expect(PinClustersToTemplate(fedResource, stable, []string{"cluster-b"})).To(Succeed())
+
+		overrides, _, _ := unstructured.NestedSlice(fedResource.Object, "spec", "overrides")
+		Expect(overrides[0].(map[string]interface{})["clusterOverrides"]).To(Equal([]interface{}{
+			map[string]interface{}{"path": "/data", "op": "remove"},
+		}))
+	})
+
+	It("Should read the revision the template was applied from", func() {
+		fedResource := federatedConfigMap(map[string]interface{}{"color": "blue"})
+		Expect(TemplateRevision(fedResource)).To(BeZero())
+		Expect(SetTemplateRevision(fedResource, 3)).To(Succeed())
+		Expect(TemplateRevision(fedResource)).To(Equal(3))
+	})
+
+	It("Should keep pinned clusters at the stable revision", func() {
+		fedResource := federatedConfigMap(map[string]interface{}{"color": "blue"})
+		stable := federatedConfigMap(map[string]interface{}{"color": "green"})
+		Expect(SetTemplateRevision(fedResource, 2)).To(Succeed())
+		Expect(PinClustersToTemplate(fedResource, stable, []string{"cluster-b"})).To(Succeed())
+		Expect(PinClustersToRevision(fedResource, []string{"cluster-b"}, 1)).To(Succeed())
+
+		annotations, _, _ := unstructured.NestedStringMap(fedResource.Object, "spec", "template", "metadata", "annotations")
+		Expect(annotations).To(HaveKeyWithValue(RevisionAnnotation, "2"))
+		overrides, _, _ := unstructured.NestedSlice(fedResource.Object, "spec", "overrides")
+		Expect(overrides).To(Equal([]interface{}{
+			map[string]interface{}{
+				"clusterName": "cluster-b",
+				"clusterOverrides": []interface{}{
+					map[string]interface{}{"path": "/data", "value": map[string]interface{}{"color": "green"}},
+					map[string]interface{}{"path": "/metadata/annotations/federation.kubefed.fulliautomatix.site~1applied-revision", "value": "1"},
+				},
+			},
+		}))
+	})
+
+	It("Should restrict placement to the updated clusters", func() {
+		fedResource := federatedConfigMap(nil)
+		Expect(RestrictPlacement(fedResource, []string{"cluster-a"})).To(Succeed())
+
+		placement, _, _ := unstructured.NestedMap(fedResource.Object, "spec", "placement")
+		Expect(placement).To(Equal(map[string]interface{}{
+			"clusters": []interface{}{map[string]interface{}{"name": "cluster-a"}},
+		}))
+	})
+
+	Context("When resolving the placement", func() {
+		clusters := fakeClusterSelector{
+			{ObjectMeta: metav1.ObjectMeta{Name: "cluster-a", Labels: map[string]string{"region": "eu"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "cluster-b", Labels: map[string]string{"region": "eu"}}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "cluster-c", Labels: map[string]string{"region": "us"}}},
+		}
+
+		It("Should combine the named and selected clusters of every federated resource", func() {
+			selected := federatedConfigMap(nil)
+			Expect(unstructured.SetNestedField(selected.Object, map[string]interface{}{"region": "eu"}, "spec", "placement", "clusterSelector", "matchLabels")).To(Succeed())
+			named := federatedConfigMap(nil)
+			Expect(RestrictPlacement(named, []string{"cluster-d", "cluster-a"})).To(Succeed())
+
+			placement, err := PlacementClusters([]*unstructured.Unstructured{selected, named}, clusters)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(placement).To(Equal([]string{"cluster-a", "cluster-b", "cluster-d"}))
+		})
+
+		It("Should place resources with an empty cluster selector on every cluster", func() {
+			placement, err := PlacementClusters([]*unstructured.Unstructured{federatedConfigMap(nil)}, clusters)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(placement).To(Equal([]string{"cluster-a", "cluster-b", "cluster-c"}))
+		})
+
+		It("Should place resources without placement nowhere", func() {
+			fedResource := federatedConfigMap(nil)
+			unstructured.RemoveNestedField(fedResource.Object, "spec", "placement")
+			placement, err := PlacementClusters([]*unstructured.Unstructured{fedResource}, clusters)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(placement).To(BeEmpty())
+		})
+	})
+})
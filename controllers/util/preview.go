@@ -0,0 +1,83 @@
+package util
+
+import (
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// PreviewClient can apply resources without persisting them
+type PreviewClient interface {
+	DynamicClient
+	DryRunApply(resourceObj unstructured.Unstructured, namespace string) (*unstructured.Unstructured, error)
+}
+
+// ResourceChange lists the changed fields of a federated object
+type ResourceChange struct {
+	Resource ResourceReference
+	Fields   []string
+}
+
+// Preview is the difference between the live federated objects and the result of applying the desired ones
+type Preview struct {
+	Added   []ResourceReference
+	Changed []ResourceChange
+	Removed []ResourceReference
+}
+
+// PreviewChanges dry run applies the desired resources and compares the result with the live objects ,
+// previous is the inventory currently deployed and used to find removed objects
+func PreviewChanges(client PreviewClient, desired []*unstructured.Unstructured, namespace string, previous []ResourceReference) (*Preview, error) {
+	preview := &Preview{}
+	inventory := NewInventory(desired, namespace)
+	for index, resource := range desired {
+		live, err := client.Get(*resource, inventory[index].Namespace)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		result, dryRunErr := client.DryRunApply(*resource, inventory[index].Namespace)
+		if dryRunErr != nil {
+			return nil, dryRunErr
+		}
+		if errors.IsNotFound(err) {
+			preview.Added = append(preview.Added, inventory[index])
+			continue
+		}
+		fields := ChangedFields(live, result)
+		if len(fields) > 0 {
+			preview.Changed = append(preview.Changed, ResourceChange{Resource: inventory[index], Fields: fields})
+		}
+	}
+
+	for _, reference := range previous {
+		found := false
+		for _, each := range inventory {
+			if each == reference {
+				found = true
+				break
+			}
+		}
+		if !found {
+			preview.Removed = append(preview.Removed, reference)
+		}
+	}
+	return preview, nil
+}
+
+// ChangedFields returns the paths of the spec and labels that differ between two versions of an object
+func ChangedFields(before *unstructured.Unstructured, after *unstructured.Unstructured) []string {
+	changed := map[string]bool{}
+	for _, field := range DriftedFields(after, before) {
+		changed[field] = true
+	}
+	for _, field := range DriftedFields(before, after) {
+		changed[field] = true
+	}
+	var fields []string
+	for field := range changed {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// fakePreviewClient returns the applied object as the dry run result
+type fakePreviewClient struct {
+	fakeDynamicClient
+}
+
+func (client fakePreviewClient) DryRunApply(resourceObj unstructured.Unstructured, namespace string) (*unstructured.Unstructured, error) {
+	return resourceObj.DeepCopy(), nil
+}
+
+var _ = Describe("preview", func() {
+	It("Should report added , changed and removed objects", func() {
+		changed := federatedConfigMap(map[string]interface{}{"color": "blue"})
+		added := federatedConfigMap(nil)
+		added.SetName("new-config")
+		client := fakePreviewClient{fakeDynamicClient{
+			"web-config": federatedConfigMap(map[string]interface{}{"color": "green", "size": "large"}),
+		}}
+		previous := []ResourceReference{
+			{APIVersion: "types.kubefed.io/v1beta1", Kind: "FederatedConfigMap", Name: "web-config", Namespace: "apps"},
+			{APIVersion: "types.kubefed.io/v1beta1", Kind: "FederatedConfigMap", Name: "old-config", Namespace: "apps"},
+		}
+
+		preview, err := PreviewChanges(client, []*unstructured.Unstructured{changed, added}, "apps", previous)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(preview.Added).To(HaveLen(1))
+		Expect(preview.Added[0].Name).To(Equal("new-config"))
+		Expect(preview.Changed).To(HaveLen(1))
+		Expect(preview.Changed[0].Fields).To(Equal([]string{".spec.template.data.color", ".spec.template.data.size"}))
+		Expect(preview.Removed).To(Equal(previous[1:]))
+	})
+})
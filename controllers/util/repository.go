@@ -0,0 +1,78 @@
+package util
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// RepositoryCredentials are the basic auth credentials of a chart repository
+type RepositoryCredentials struct {
+	Username string
+	Password string
+}
+
+// RepositoryIndex lists the charts of a chart repository
+type RepositoryIndex struct {
+	// Versions of every chart , newest first
+	Charts map[string][]string
+	// Digest of the downloaded index.yaml
+	Digest string
+}
+
+// ChartNames are the names of the charts in the index , sorted
+func (index *RepositoryIndex) ChartNames() []string {
+	var names []string
+	for name := range index.Charts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FetchRepositoryIndex downloads the index.yaml of the repository into the helm repository cache and
+// lists its charts , charts resolved by repository url still download the index on their own
+func FetchRepositoryIndex(name string, url string, credentials RepositoryCredentials) (*RepositoryIndex, error) {
+	return fetchRepositoryIndex(name, url, credentials, cli.New())
+}
+
+func fetchRepositoryIndex(name string, url string, credentials RepositoryCredentials, settings *cli.EnvSettings) (*RepositoryIndex, error) {
+	chartRepository, err := repo.NewChartRepository(&repo.Entry{
+		Name:     name,
+		URL:      url,
+		Username: credentials.Username,
+		Password: credentials.Password,
+	}, getter.All(settings))
+	if err != nil {
+		return nil, err
+	}
+	chartRepository.CachePath = settings.RepositoryCache
+	indexPath, err := chartRepository.DownloadIndexFile()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to download the index of repository %s: %v", url, err)
+	}
+	data, err := ioutil.ReadFile(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	indexFile, err := repo.LoadIndexFile(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid index of repository %s: %v", url, err)
+	}
+	index := &RepositoryIndex{
+		Charts: map[string][]string{},
+		Digest: fmt.Sprintf("%x", sha256.Sum256(data)),
+	}
+	// the entries are sorted newest first when loaded
+	for chartName, versions := range indexFile.Entries {
+		for _, version := range versions {
+			index.Charts[chartName] = append(index.Charts[chartName], version.Version)
+		}
+	}
+	return index, nil
+}
@@ -0,0 +1,86 @@
+package util
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+var _ = Describe("repository index", func() {
+	var (
+		dir      string
+		repoDir  string
+		server   *httptest.Server
+		settings *cli.EnvSettings
+	)
+
+	writeIndex := func(versions ...string) {
+		index := repo.NewIndexFile()
+		for _, version := range versions {
+			index.Add(&chart.Metadata{APIVersion: "v2", Name: "web", Version: version}, "web-"+version+".tgz", server.URL, "")
+		}
+		index.Add(&chart.Metadata{APIVersion: "v2", Name: "api", Version: "2.0.0"}, "api-2.0.0.tgz", server.URL, "")
+		Expect(index.WriteFile(filepath.Join(repoDir, "index.yaml"), 0644)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "repository")
+		Expect(err).ToNot(HaveOccurred())
+		repoDir = filepath.Join(dir, "repo")
+		Expect(os.MkdirAll(repoDir, 0755)).To(Succeed())
+
+		files := http.FileServer(http.Dir(repoDir))
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			files.ServeHTTP(w, r)
+		}))
+		settings = &cli.EnvSettings{RepositoryCache: filepath.Join(dir, "cache")}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("Should list the charts and versions of the index , newest first", func() {
+		writeIndex("0.1.0", "0.2.0")
+		index, err := fetchRepositoryIndex("team-web", server.URL, RepositoryCredentials{Username: "user", Password: "secret"}, settings)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(index.ChartNames()).To(Equal([]string{"api", "web"}))
+		Expect(index.Charts["web"]).To(Equal([]string{"0.2.0", "0.1.0"}))
+		Expect(filepath.Join(dir, "cache", "team-web-index.yaml")).To(BeAnExistingFile())
+	})
+
+	It("Should change the digest when the index changes", func() {
+		writeIndex("0.1.0")
+		credentials := RepositoryCredentials{Username: "user", Password: "secret"}
+		first, err := fetchRepositoryIndex("team-web", server.URL, credentials, settings)
+		Expect(err).ToNot(HaveOccurred())
+		unchanged, err := fetchRepositoryIndex("team-web", server.URL, credentials, settings)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(unchanged.Digest).To(Equal(first.Digest))
+
+		writeIndex("0.1.0", "0.2.0")
+		changed, err := fetchRepositoryIndex("team-web", server.URL, credentials, settings)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changed.Digest).ToNot(Equal(first.Digest))
+	})
+
+	It("Should fail without the repository credentials", func() {
+		writeIndex("0.1.0")
+		_, err := fetchRepositoryIndex("team-web", server.URL, RepositoryCredentials{}, settings)
+		Expect(err).To(HaveOccurred())
+	})
+})
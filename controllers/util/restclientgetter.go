@@ -0,0 +1,193 @@
+package util
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	memory "k8s.io/client-go/discovery/cached"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// discoveryCacheTTL is how long discovered kinds are trusted before the cluster is asked again
+const discoveryCacheTTL = 5 * time.Minute
+
+// maxImpersonatedConfigs bounds the impersonating rest configs and their getters kept by the process
+const maxImpersonatedConfigs = 256
+
+// RESTClientGetter hands out the rest config it was created with as is , instead of rebuilding it from
+// kubeconfig flags , so token files , client certificates , auth plugins and impersonation keep working
+type RESTClientGetter struct {
+	config    *rest.Config
+	namespace string
+	discovery *discoveryCache
+}
+
+var _ genericclioptions.RESTClientGetter = &RESTClientGetter{}
+
+// discoveryCache is the discovery client and REST mapper shared by the getters of a rest config , it is
+// reset once expired so kinds installed later , e.g. newly federated types , are found
+type discoveryCache struct {
+	mutex      sync.Mutex
+	client     discovery.CachedDiscoveryInterface
+	restMapper *restmapper.DeferredDiscoveryRESTMapper
+	resetAt    time.Time
+}
+
+func (cache *discoveryCache) expire() {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if time.Since(cache.resetAt) >= discoveryCacheTTL {
+		cache.reset()
+	}
+}
+
+func (cache *discoveryCache) reset() {
+	cache.restMapper.Reset()
+	cache.resetAt = time.Now()
+}
+
+var (
+	restClientGettersMutex sync.Mutex
+	// restClientGetters shares the discovery cache and REST mapper of every rest config
+	restClientGetters = map[*rest.Config]*RESTClientGetter{}
+)
+
+// NewRESTClientGetter returns the getter of the rest config , created once per config
+func NewRESTClientGetter(config *rest.Config) (*RESTClientGetter, error) {
+	restClientGettersMutex.Lock()
+	defer restClientGettersMutex.Unlock()
+	if getter, ok := restClientGetters[config]; ok {
+		return getter, nil
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	cachedDiscovery := memory.NewMemCacheClient(discoveryClient)
+	getter := &RESTClientGetter{
+		config: config,
+		discovery: &discoveryCache{
+			client:     cachedDiscovery,
+			restMapper: restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscovery),
+			resetAt:    time.Now(),
+		},
+	}
+	restClientGetters[config] = getter
+	return getter, nil
+}
+
+var (
+	impersonatedConfigsMutex sync.Mutex
+	// impersonatedConfigs keeps a single impersonating rest config per service account , so their
+	// getters are shared as well , the oldest are dropped beyond maxImpersonatedConfigs
+	impersonatedConfigs = map[impersonation]*rest.Config{}
+	impersonationOrder  []impersonation
+)
+
+type impersonation struct {
+	config   *rest.Config
+	userName string
+}
+
+// ImpersonatedConfig returns a copy of the rest config impersonating the service account
+func ImpersonatedConfig(config *rest.Config, namespace string, serviceAccountName string) *rest.Config {
+	key := impersonation{config: config, userName: fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccountName)}
+	impersonatedConfigsMutex.Lock()
+	defer impersonatedConfigsMutex.Unlock()
+	if impersonated, ok := impersonatedConfigs[key]; ok {
+		return impersonated
+	}
+	if len(impersonationOrder) >= maxImpersonatedConfigs {
+		oldest := impersonationOrder[0]
+		impersonationOrder = impersonationOrder[1:]
+		restClientGettersMutex.Lock()
+		delete(restClientGetters, impersonatedConfigs[oldest])
+		restClientGettersMutex.Unlock()
+		delete(impersonatedConfigs, oldest)
+	}
+	impersonated := rest.CopyConfig(config)
+	impersonated.Impersonate = rest.ImpersonationConfig{UserName: key.userName}
+	impersonatedConfigs[key] = impersonated
+	impersonationOrder = append(impersonationOrder, key)
+	return impersonated
+}
+
+// WithNamespace returns a getter defaulting to the namespace , sharing the discovery cache and REST mapper
+func (getter *RESTClientGetter) WithNamespace(namespace string) *RESTClientGetter {
+	namespaced := *getter
+	namespaced.namespace = namespace
+	return &namespaced
+}
+
+func (getter *RESTClientGetter) ToRESTConfig() (*rest.Config, error) {
+	return rest.CopyConfig(getter.config), nil
+}
+
+func (getter *RESTClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	getter.discovery.expire()
+	return getter.discovery.client, nil
+}
+
+func (getter *RESTClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	getter.discovery.expire()
+	return getter.discovery.restMapper, nil
+}
+
+// CheckServed fails when the cluster does not serve the kind of a resource , unknown kinds are
+// looked up again since the cached discovery never refreshes on its own
+func (getter *RESTClientGetter) CheckServed(resources []*unstructured.Unstructured) error {
+	getter.discovery.expire()
+	for _, resource := range resources {
+		gvk := resource.GroupVersionKind()
+		_, err := getter.discovery.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			getter.discovery.mutex.Lock()
+			getter.discovery.reset()
+			getter.discovery.mutex.Unlock()
+			_, err = getter.discovery.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+		if err != nil {
+			if meta.IsNoMatchError(err) {
+				return fmt.Errorf("%s %s is not served by the cluster , is the federated type enabled?", gvk.Kind, resource.GetName())
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (getter *RESTClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return &restClientConfig{getter: getter}
+}
+
+// restClientConfig is the clientcmd view of the getter , there is no kubeconfig file behind it
+type restClientConfig struct {
+	getter *RESTClientGetter
+}
+
+func (clientConfig *restClientConfig) RawConfig() (clientcmdapi.Config, error) {
+	return *clientcmdapi.NewConfig(), nil
+}
+
+func (clientConfig *restClientConfig) ClientConfig() (*rest.Config, error) {
+	return clientConfig.getter.ToRESTConfig()
+}
+
+func (clientConfig *restClientConfig) Namespace() (string, bool, error) {
+	if clientConfig.getter.namespace == "" {
+		return "default", false, nil
+	}
+	return clientConfig.getter.namespace, true, nil
+}
+
+func (clientConfig *restClientConfig) ConfigAccess() clientcmd.ConfigAccess {
+	return clientcmd.NewDefaultClientConfigLoadingRules()
+}
@@ -0,0 +1,110 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+)
+
+var _ = Describe("rest client getter", func() {
+	config := &rest.Config{
+		Host:            "https://host.example:6443",
+		BearerToken:     "token",
+		TLSClientConfig: rest.TLSClientConfig{ServerName: "host.example"},
+		Impersonate:     rest.ImpersonationConfig{UserName: "system:serviceaccount:apps:deployer"},
+	}
+
+	It("Should hand out the rest config as is", func() {
+		getter, err := NewRESTClientGetter(config)
+		Expect(err).ToNot(HaveOccurred())
+		restConfig, err := getter.ToRESTConfig()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(restConfig).To(Equal(config))
+		Expect(restConfig).ToNot(BeIdenticalTo(config))
+	})
+
+	It("Should share the REST mapper of a rest config", func() {
+		getter, err := NewRESTClientGetter(config)
+		Expect(err).ToNot(HaveOccurred())
+		other, err := NewRESTClientGetter(config)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other).To(BeIdenticalTo(getter))
+
+		namespaced := getter.WithNamespace("apps")
+		mapper, _ := getter.ToRESTMapper()
+		namespacedMapper, _ := namespaced.ToRESTMapper()
+		Expect(namespacedMapper).To(BeIdenticalTo(mapper))
+		namespace, overridden, err := namespaced.ToRawKubeConfigLoader().Namespace()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(overridden).To(BeTrue())
+		Expect(namespace).To(Equal("apps"))
+	})
+
+	It("Should impersonate the service account with a single config per account", func() {
+		impersonated := ImpersonatedConfig(config, "apps", "deployer")
+		Expect(impersonated.Impersonate.UserName).To(Equal("system:serviceaccount:apps:deployer"))
+		Expect(impersonated.BearerToken).To(Equal(config.BearerToken))
+		Expect(ImpersonatedConfig(config, "apps", "deployer")).To(BeIdenticalTo(impersonated))
+		Expect(ImpersonatedConfig(config, "apps", "other")).ToNot(BeIdenticalTo(impersonated))
+	})
+
+	It("Should drop the oldest impersonated configs", func() {
+		first := ImpersonatedConfig(config, "bounded", "account-0")
+		for i := 1; i <= maxImpersonatedConfigs; i++ {
+			ImpersonatedConfig(config, "bounded", fmt.Sprintf("account-%d", i))
+		}
+		Expect(len(impersonatedConfigs)).To(BeNumerically("<=", maxImpersonatedConfigs))
+		Expect(ImpersonatedConfig(config, "bounded", "account-0")).ToNot(BeIdenticalTo(first))
+	})
+
+	It("Should discover kinds served after the first lookup", func() {
+		var federated int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body interface{}
+			switch r.URL.Path {
+			case "/api":
+				body = metav1.APIVersions{Versions: []string{"v1"}}
+			case "/api/v1":
+				body = metav1.APIResourceList{GroupVersion: "v1", APIResources: []metav1.APIResource{
+					{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get"}},
+				}}
+			case "/apis":
+				groups := metav1.APIGroupList{}
+				if atomic.LoadInt32(&federated) == 1 {
+					version := metav1.GroupVersionForDiscovery{GroupVersion: "types.kubefed.io/v1beta1", Version: "v1beta1"}
+					groups.Groups = append(groups.Groups, metav1.APIGroup{Name: "types.kubefed.io", Versions: []metav1.GroupVersionForDiscovery{version}, PreferredVersion: version})
+				}
+				body = groups
+			case "/apis/types.kubefed.io/v1beta1":
+				body = metav1.APIResourceList{GroupVersion: "types.kubefed.io/v1beta1", APIResources: []metav1.APIResource{
+					{Name: "federateddeployments", Kind: "FederatedDeployment", Namespaced: true, Verbs: metav1.Verbs{"get"}},
+				}}
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(body)
+		}))
+		defer server.Close()
+
+		getter, err := NewRESTClientGetter(&rest.Config{Host: server.URL})
+		Expect(err).ToNot(HaveOccurred())
+		resource := &unstructured.Unstructured{}
+		resource.SetAPIVersion("types.kubefed.io/v1beta1")
+		resource.SetKind("FederatedDeployment")
+		resource.SetName("web")
+		Expect(getter.CheckServed([]*unstructured.Unstructured{resource})).To(HaveOccurred())
+
+		atomic.StoreInt32(&federated, 1)
+		Expect(getter.CheckServed([]*unstructured.Unstructured{resource})).To(Succeed())
+	})
+})
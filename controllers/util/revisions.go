@@ -0,0 +1,251 @@
+package util
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	revisionSecretType = "federation.kubefed.fulliautomatix.site/revision.v1"
+	revisionDataKey    = "revision"
+
+	RevisionOwnerLabel  = "federation.kubefed.fulliautomatix.site/application"
+	RevisionNumberLabel = "federation.kubefed.fulliautomatix.site/revision"
+	RevisionStatusLabel = "federation.kubefed.fulliautomatix.site/revision-status"
+
+	// RevisionAnnotation on the objects propagated to the member clusters is the revision they were applied from
+	RevisionAnnotation = "federation.kubefed.fulliautomatix.site/applied-revision"
+)
+
+// RevisionStatus tracks whether a revision was deployed successfully
+type RevisionStatus string
+
+const (
+	RevisionPending   RevisionStatus = "Pending"
+	RevisionSucceeded RevisionStatus = "Succeeded"
+	RevisionFailed    RevisionStatus = "Failed"
+)
+
+// RevisionInputs are everything the rendered output of an application depends on
+type RevisionInputs struct {
+	ReleaseName string                 `json:"releaseName"`
+	Chart       string                 `json:"chart"`
+	Repo        string                 `json:"repo"`
+	Version     string                 `json:"version,omitempty"`
+	Namespace   string                 `json:"namespace"`
+	Values      map[string]interface{} `json:"values,omitempty"`
+	KubeVersion string                 `json:"kubeVersion,omitempty"`
+	APIVersions []string               `json:"apiVersions,omitempty"`
+}
+
+// Hash returns a stable digest of the inputs
+func (inputs RevisionInputs) Hash() string {
+	// encoding/json sorts map keys so the digest is stable
+	encoded, _ := json.Marshal(inputs)
+	return fmt.Sprintf("%x", sha256.Sum256(encoded))
+}
+
+// ValuesHash returns a stable digest of the chart values
+func (inputs RevisionInputs) ValuesHash() string {
+	encoded, _ := json.Marshal(inputs.Values)
+	return fmt.Sprintf("%x", sha256.Sum256(encoded))
+}
+
+// ResourceReference identifies a federated object created by a revision
+type ResourceReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+}
+
+// NewInventory lists the federated objects , defaulting their namespace
+func NewInventory(fedResources []*unstructured.Unstructured, namespace string) []ResourceReference {
+	var inventory []ResourceReference
+	for _, resource := range fedResources {
+		resourceNamespace := resource.GetNamespace()
+		if resourceNamespace == "" {
+			resourceNamespace = namespace
+		}
+		inventory = append(inventory, ResourceReference{
+			APIVersion: resource.GetAPIVersion(),
+			Kind:       resource.GetKind(),
+			Name:       resource.GetName(),
+			Namespace:  resourceNamespace,
+		})
+	}
+	return inventory
+}
+
+// Revision is a snapshot of a deployed application
+type Revision struct {
+	Number            int                 `json:"number"`
+	Inputs            RevisionInputs      `json:"inputs"`
+	InputsHash        string              `json:"inputsHash"`
+	ChartVersion      string              `json:"chartVersion,omitempty"`
+	Manifest          string              `json:"manifest"`
+	FederatedManifest string              `json:"federatedManifest"`
+	Inventory         []ResourceReference `json:"inventory,omitempty"`
+	Status            RevisionStatus      `json:"status"`
+	Timestamp         metav1.Time         `json:"timestamp"`
+}
+
+// RevisionStore persists the revision history of an application
+type RevisionStore interface {
+	List(namespace string, owner string) ([]*Revision, error)
+	Create(namespace string, owner string, ownerRef metav1.OwnerReference, revision *Revision) error
+	Update(namespace string, owner string, revision *Revision) error
+	Delete(namespace string, owner string, number int) error
+}
+
+// SecretRevisionStore keeps every revision in a compressed Secret next to the application , similar to helm's release storage
+type SecretRevisionStore struct {
+	client client.Client
+	reader client.Reader
+}
+
+// NewSecretRevisionStore creates a revision store backed by Secrets , listing them with the reader since the
+// cache of the client may miss a revision created a moment ago and number the next one the same
+func NewSecretRevisionStore(client client.Client, reader client.Reader) *SecretRevisionStore {
+	return &SecretRevisionStore{client: client, reader: reader}
+}
+
+// List returns the revisions of the owner sorted by revision number
+func (store *SecretRevisionStore) List(namespace string, owner string) ([]*Revision, error) {
+	var secrets corev1.SecretList
+	err := store.reader.List(context.TODO(), &secrets, client.InNamespace(namespace), client.MatchingLabels{RevisionOwnerLabel: owner})
+	if err != nil {
+		return nil, err
+	}
+	var revisions []*Revision
+	for _, secret := range secrets.Items {
+		if secret.Type != revisionSecretType {
+			continue
+		}
+		revision, err := decodeRevision(secret.Data[revisionDataKey])
+		if err != nil {
+			return nil, fmt.Errorf("Unable to decode revision %s: %v", secret.Name, err)
+		}
+		revisions = append(revisions, revision)
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Number < revisions[j].Number })
+	return revisions, nil
+}
+
+func (store *SecretRevisionStore) Create(namespace string, owner string, ownerRef metav1.OwnerReference, revision *Revision) error {
+	secret, err := newRevisionSecret(namespace, owner, revision)
+	if err != nil {
+		return err
+	}
+	secret.OwnerReferences = []metav1.OwnerReference{ownerRef}
+	return store.client.Create(context.TODO(), secret)
+}
+
+func (store *SecretRevisionStore) Update(namespace string, owner string, revision *Revision) error {
+	var existing corev1.Secret
+	err := store.client.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: RevisionSecretName(owner, revision.Number)}, &existing)
+	if err != nil {
+		return err
+	}
+	secret, err := newRevisionSecret(namespace, owner, revision)
+	if err != nil {
+		return err
+	}
+	existing.Labels = secret.Labels
+	existing.Data = secret.Data
+	return store.client.Update(context.TODO(), &existing)
+}
+
+func (store *SecretRevisionStore) Delete(namespace string, owner string, number int) error {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: RevisionSecretName(owner, number)}}
+	return client.IgnoreNotFound(store.client.Delete(context.TODO(), secret))
+}
+
+// PrunableRevisions returns the revisions beyond the newest limit ones , except the revisions in use
+func PrunableRevisions(revisions []*Revision, limit int, inUse ...int) []*Revision {
+	var prunable []*Revision
+	for index, revision := range revisions {
+		if index >= len(revisions)-limit || containsRevision(inUse, revision.Number) {
+			continue
+		}
+		prunable = append(prunable, revision)
+	}
+	return prunable
+}
+
+func containsRevision(numbers []int, number int) bool {
+	for _, n := range numbers {
+		if n == number {
+			return true
+		}
+	}
+	return false
+}
+
+// RevisionSecretName is the name of the Secret holding a revision of the owner
+func RevisionSecretName(owner string, number int) string {
+	return fmt.Sprintf("%s.v%d", owner, number)
+}
+
+func newRevisionSecret(namespace string, owner string, revision *Revision) (*corev1.Secret, error) {
+	data, err := encodeRevision(revision)
+	if err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      RevisionSecretName(owner, revision.Number),
+			Namespace: namespace,
+			Labels: map[string]string{
+				RevisionOwnerLabel:  owner,
+				RevisionNumberLabel: strconv.Itoa(revision.Number),
+				RevisionStatusLabel: string(revision.Status),
+			},
+		},
+		Type: revisionSecretType,
+		Data: map[string][]byte{revisionDataKey: data},
+	}, nil
+}
+
+func encodeRevision(revision *Revision) ([]byte, error) {
+	encoded, err := json.Marshal(revision)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(encoded); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeRevision(data []byte) (*Revision, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	decoded, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	revision := &Revision{}
+	err = json.Unmarshal(decoded, revision)
+	return revision, err
+}
@@ -0,0 +1,98 @@
+package util
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("secret revision store", func() {
+	ownerRef := metav1.OwnerReference{APIVersion: "federation.kubefed.fulliautomatix.site/v1", Kind: "Application", Name: "web", UID: "1234"}
+
+	It("Should round trip revisions in number order", func() {
+		client := fake.NewFakeClientWithScheme(scheme.Scheme)
+		store := NewSecretRevisionStore(client, client)
+		inputs := RevisionInputs{ReleaseName: "web", Chart: "nginx", Namespace: "apps", Values: map[string]interface{}{"replicaCount": float64(2)}}
+		for _, number := range []int{2, 1} {
+			revision := &Revision{Number: number, Inputs: inputs, InputsHash: inputs.Hash(), FederatedManifest: "kind: FederatedDeployment", Status: RevisionPending}
+			Expect(store.Create("default", "web", ownerRef, revision)).To(Succeed())
+		}
+
+		revisions, err := store.List("default", "web")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(revisions).To(HaveLen(2))
+		Expect(revisions[0].Number).To(Equal(1))
+		Expect(revisions[1].Inputs).To(Equal(inputs))
+
+		revisions[1].Status = RevisionSucceeded
+		Expect(store.Update("default", "web", revisions[1])).To(Succeed())
+		revisions, err = store.List("default", "web")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(revisions[1].Status).To(Equal(RevisionSucceeded))
+
+		other, err := store.List("default", "other")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other).To(BeEmpty())
+	})
+
+	It("Should list revisions with the reader", func() {
+		cached := fake.NewFakeClientWithScheme(scheme.Scheme)
+		live := fake.NewFakeClientWithScheme(scheme.Scheme)
+		revision := &Revision{Number: 1, Status: RevisionPending}
+		Expect(NewSecretRevisionStore(live, live).Create("default", "web", ownerRef, revision)).To(Succeed())
+
+		revisions, err := NewSecretRevisionStore(cached, live).List("default", "web")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(revisions).To(HaveLen(1))
+	})
+
+	It("Should hash inputs independently of map ordering", func() {
+		first := RevisionInputs{Chart: "nginx", Values: map[string]interface{}{"a": "1", "b": "2"}}
+		second := RevisionInputs{Chart: "nginx", Values: map[string]interface{}{"b": "2", "a": "1"}}
+		Expect(first.Hash()).To(Equal(second.Hash()))
+		second.Version = "1.0.0"
+		Expect(first.Hash()).ToNot(Equal(second.Hash()))
+	})
+
+	It("Should delete revisions and ignore missing ones", func() {
+		client := fake.NewFakeClientWithScheme(scheme.Scheme)
+		store := NewSecretRevisionStore(client, client)
+		for _, number := range []int{1, 2} {
+			Expect(store.Create("default", "web", ownerRef, &Revision{Number: number, Status: RevisionSucceeded})).To(Succeed())
+		}
+
+		Expect(store.Delete("default", "web", 1)).To(Succeed())
+		Expect(store.Delete("default", "web", 1)).To(Succeed())
+		revisions, err := store.List("default", "web")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(revisions).To(HaveLen(1))
+		Expect(revisions[0].Number).To(Equal(2))
+	})
+})
+
+var _ = Describe("revision pruning", func() {
+	numbers := func(revisions []*Revision) []int {
+		var result []int
+		for _, revision := range revisions {
+			result = append(result, revision.Number)
+		}
+		return result
+	}
+	history := []*Revision{{Number: 1}, {Number: 2}, {Number: 3}, {Number: 4}, {Number: 5}}
+
+	It("Should prune the revisions beyond the history limit", func() {
+		Expect(numbers(PrunableRevisions(history, 2, 5))).To(Equal([]int{1, 2, 3}))
+	})
+
+	It("Should keep the revisions in use", func() {
+		// e.g. the current revision 1 and the revision 2 rolled back to
+		Expect(numbers(PrunableRevisions(history, 1, 2, 1))).To(Equal([]int{3, 4}))
+	})
+
+	It("Should keep everything within the history limit", func() {
+		Expect(PrunableRevisions(history, 5)).To(BeEmpty())
+		Expect(PrunableRevisions(history, 10)).To(BeEmpty())
+	})
+})
@@ -1,34 +1,45 @@
 package util
 
 import (
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/discovery"
-	memory "k8s.io/client-go/discovery/cached"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/restmapper"
 )
 
+// FieldManager is the field manager of every server side apply of the controller
+const FieldManager = "kubefed-helm-controller"
+
 type DynamicClient interface {
 	Apply(resourceObj unstructured.Unstructured, namespace string) error
+	Get(resourceObj unstructured.Unstructured, namespace string) (*unstructured.Unstructured, error)
+	Delete(resourceObj unstructured.Unstructured, namespace string) error
 }
 
 type ServerSideDeployer struct {
 	config        *rest.Config
 	dynamicClient dynamic.Interface
-	restMapper    *restmapper.DeferredDiscoveryRESTMapper
+	restMapper    meta.RESTMapper
+	// Force takes ownership of fields managed by other field managers instead of failing with a conflict
+	Force bool
 }
 
 func NewServerSideDeployer(config *rest.Config) (*ServerSideDeployer, error) {
-	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	getter, err := NewRESTClientGetter(config)
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := getter.ToRESTMapper()
 	if err != nil {
 		return nil, err
 	}
-
-	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
 
 	// 2. Prepare the dynamic client
 	dynamicClient, err := dynamic.NewForConfig(config)
@@ -40,23 +51,112 @@ func NewServerSideDeployer(config *rest.Config) (*ServerSideDeployer, error) {
 }
 
 func (ssd *ServerSideDeployer) Apply(resourceObj unstructured.Unstructured, namespace string) error {
-	// first get the gvk
-	gvk := resourceObj.GroupVersionKind()
-	// find the group version resource for the gvk
-	gvrMapping, err := ssd.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	dynamicResource, err := ssd.resourceInterface(resourceObj, namespace)
 	if err != nil {
 		return err
 	}
-
-	dynamicResource := ssd.dynamicClient.Resource(gvrMapping.Resource).Namespace(namespace)
 	resourceObjJson, err := runtime.Encode(unstructured.UnstructuredJSONScheme, &resourceObj)
 	if err != nil {
 		return err
 	}
 
 	_, err = dynamicResource.Patch(resourceObj.GetName(), types.ApplyPatchType, resourceObjJson, metav1.PatchOptions{
-		FieldManager: "kubefed-helm-controller",
+		FieldManager: FieldManager,
+		Force:        &ssd.Force,
 	})
 
 	return err
 }
+
+// DryRunApply server side applies the resource without persisting it and returns the resulting object
+func (ssd *ServerSideDeployer) DryRunApply(resourceObj unstructured.Unstructured, namespace string) (*unstructured.Unstructured, error) {
+	dynamicResource, err := ssd.resourceInterface(resourceObj, namespace)
+	if err != nil {
+		return nil, err
+	}
+	resourceObjJson, err := runtime.Encode(unstructured.UnstructuredJSONScheme, &resourceObj)
+	if err != nil {
+		return nil, err
+	}
+	return dynamicResource.Patch(resourceObj.GetName(), types.ApplyPatchType, resourceObjJson, metav1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        &ssd.Force,
+		DryRun:       []string{metav1.DryRunAll},
+	})
+}
+
+// Get fetches the live state of the resource
+func (ssd *ServerSideDeployer) Get(resourceObj unstructured.Unstructured, namespace string) (*unstructured.Unstructured, error) {
+	dynamicResource, err := ssd.resourceInterface(resourceObj, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return dynamicResource.Get(resourceObj.GetName(), metav1.GetOptions{})
+}
+
+// Delete deletes the resource , its dependents are deleted in the background
+func (ssd *ServerSideDeployer) Delete(resourceObj unstructured.Unstructured, namespace string) error {
+	dynamicResource, err := ssd.resourceInterface(resourceObj, namespace)
+	if err != nil {
+		return err
+	}
+	propagation := metav1.DeletePropagationBackground
+	return dynamicResource.Delete(resourceObj.GetName(), &metav1.DeleteOptions{PropagationPolicy: &propagation})
+}
+
+func (ssd *ServerSideDeployer) resourceInterface(resourceObj unstructured.Unstructured, namespace string) (dynamic.ResourceInterface, error) {
+	// first get the gvk
+	gvk := resourceObj.GroupVersionKind()
+	// find the group version resource for the gvk
+	gvrMapping, err := ssd.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	return ssd.dynamicClient.Resource(gvrMapping.Resource).Namespace(namespace), nil
+}
+
+var conflictManager = regexp.MustCompile(`conflict with "([^"]*)"`)
+
+// FieldConflict is a field of an applied object owned by another field manager
+type FieldConflict struct {
+	Field   string
+	Manager string
+}
+
+// ParseConflicts extracts the conflicting fields of a failed server side apply , it returns nil for other errors
+func ParseConflicts(err error) []FieldConflict {
+	if !errors.IsConflict(err) {
+		return nil
+	}
+	statusErr, ok := err.(errors.APIStatus)
+	if !ok || statusErr.Status().Details == nil {
+		return nil
+	}
+	var conflicts []FieldConflict
+	for _, cause := range statusErr.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		conflict := FieldConflict{Field: cause.Field}
+		if match := conflictManager.FindStringSubmatch(cause.Message); match != nil {
+			conflict.Manager = match[1]
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
+
+// RemoveFields drops the fields at the given paths , e.g. .spec.template.spec.replicas , so they are no longer applied
+func RemoveFields(resourceObj *unstructured.Unstructured, paths []string) {
+	for _, path := range paths {
+		var fields []string
+		for _, field := range strings.Split(path, ".") {
+			if field != "" {
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) > 0 {
+			unstructured.RemoveNestedField(resourceObj.Object, fields...)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("server side apply", func() {
+	It("Should parse the conflicting fields and their managers", func() {
+		err := &errors.StatusError{ErrStatus: metav1.Status{
+			Status: metav1.StatusFailure,
+			Code:   409,
+			Reason: metav1.StatusReasonConflict,
+			Details: &metav1.StatusDetails{Causes: []metav1.StatusCause{{
+				Type:    metav1.CauseTypeFieldManagerConflict,
+				Message: `conflict with "kubectl-edit" using types.kubefed.io/v1beta1 at 2020-06-01T10:00:00Z`,
+				Field:   ".spec.template.spec.replicas",
+			}}},
+		}}
+		Expect(ParseConflicts(err)).To(Equal([]FieldConflict{{Field: ".spec.template.spec.replicas", Manager: "kubectl-edit"}}))
+		Expect(ParseConflicts(fmt.Errorf("connection refused"))).To(BeNil())
+		Expect(ParseConflicts(nil)).To(BeNil())
+	})
+
+	It("Should remove released fields", func() {
+		fedResource := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{"replicas": int64(3), "paused": false}}},
+		}}
+		RemoveFields(fedResource, []string{".spec.template.spec.replicas", ".spec.missing"})
+		Expect(fedResource.Object["spec"]).To(Equal(map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{"paused": false}}}))
+	})
+})
@@ -0,0 +1,99 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// ValueFieldError is a chart value violating the values.schema.json of the chart
+type ValueFieldError struct {
+	// Dot separated path of the value , empty for the values themselves
+	Path    string
+	Value   interface{}
+	Message string
+}
+
+// ValuesError lists the chart values violating the values.schema.json of the chart or its subcharts
+type ValuesError struct {
+	Errors []ValueFieldError
+}
+
+func (err *ValuesError) Error() string {
+	var messages []string
+	for _, each := range err.Errors {
+		path := each.Path
+		if path == "" {
+			path = "(root)"
+		}
+		messages = append(messages, fmt.Sprintf("%s: %s", path, each.Message))
+	}
+	return fmt.Sprintf("Values do not match the chart schema: %s", strings.Join(messages, " , "))
+}
+
+// ValidateValues checks the values merged with the chart defaults against the schemas of the chart
+// and its subcharts , returning a ValuesError with the path of every invalid value
+func ValidateValues(chrt *chart.Chart, values map[string]interface{}) error {
+	coalesced, err := chartutil.CoalesceValues(chrt, values)
+	if err != nil {
+		return err
+	}
+	fieldErrors, err := validateChartValues(chrt, coalesced, "")
+	if err != nil {
+		return err
+	}
+	if len(fieldErrors) > 0 {
+		sort.SliceStable(fieldErrors, func(i, j int) bool { return fieldErrors[i].Path < fieldErrors[j].Path })
+		return &ValuesError{Errors: fieldErrors}
+	}
+	return nil
+}
+
+func validateChartValues(chrt *chart.Chart, values map[string]interface{}, prefix string) ([]ValueFieldError, error) {
+	var fieldErrors []ValueFieldError
+	if chrt.Schema != nil {
+		valuesJSON, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(valuesJSON, []byte("null")) {
+			valuesJSON = []byte("{}")
+		}
+		result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(chrt.Schema), gojsonschema.NewBytesLoader(valuesJSON))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid values schema of chart %s: %v", chrt.Name(), err)
+		}
+		for _, resultError := range result.Errors() {
+			fieldErrors = append(fieldErrors, ValueFieldError{
+				Path:    joinValuePath(prefix, resultError.Field()),
+				Value:   resultError.Value(),
+				Message: resultError.Description(),
+			})
+		}
+	}
+	for _, subchart := range chrt.Dependencies() {
+		subchartValues, _ := values[subchart.Name()].(map[string]interface{})
+		subchartErrors, err := validateChartValues(subchart, subchartValues, joinValuePath(prefix, subchart.Name()))
+		if err != nil {
+			return nil, err
+		}
+		fieldErrors = append(fieldErrors, subchartErrors...)
+	}
+	return fieldErrors, nil
+}
+
+func joinValuePath(prefix string, field string) string {
+	if field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+		field = ""
+	}
+	if prefix == "" || field == "" {
+		return prefix + field
+	}
+	return prefix + "." + field
+}
@@ -0,0 +1,57 @@
+package util
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+var _ = Describe("values validation", func() {
+	schema := []byte(`{
+		"type": "object",
+		"properties": {
+			"replicaCount": {"type": "integer", "minimum": 1},
+			"ports": {"type": "array", "items": {"type": "integer"}}
+		}
+	}`)
+
+	newChart := func() *chart.Chart {
+		chrt := &chart.Chart{
+			Metadata: &chart.Metadata{Name: "web", Version: "0.1.0", APIVersion: chart.APIVersionV2},
+			Values:   map[string]interface{}{"replicaCount": 1},
+			Schema:   schema,
+		}
+		chrt.AddDependency(&chart.Chart{
+			Metadata: &chart.Metadata{Name: "cache", Version: "0.1.0", APIVersion: chart.APIVersionV2},
+			Values:   map[string]interface{}{"enabled": true},
+			Schema:   []byte(`{"type": "object", "properties": {"enabled": {"type": "boolean"}}}`),
+		})
+		return chrt
+	}
+
+	It("Should accept values matching the schemas", func() {
+		Expect(ValidateValues(newChart(), map[string]interface{}{"replicaCount": 3})).To(Succeed())
+	})
+
+	It("Should report the path of every invalid value", func() {
+		err := ValidateValues(newChart(), map[string]interface{}{
+			"replicaCount": 0,
+			"ports":        []interface{}{80, "http"},
+			"cache":        map[string]interface{}{"enabled": "yes"},
+		})
+		Expect(err).To(HaveOccurred())
+		valuesErr, ok := err.(*ValuesError)
+		Expect(ok).To(BeTrue())
+		var paths []string
+		for _, each := range valuesErr.Errors {
+			paths = append(paths, each.Path)
+		}
+		Expect(paths).To(Equal([]string{"cache.enabled", "ports.1", "replicaCount"}))
+	})
+
+	It("Should ignore charts without a schema", func() {
+		chrt := newChart()
+		chrt.Schema = nil
+		Expect(ValidateValues(chrt, map[string]interface{}{"replicaCount": "many"})).To(Succeed())
+	})
+})
@@ -0,0 +1,130 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	federationv1 "kubefed-application-controller/api/v1"
+	"kubefed-application-controller/controllers/util"
+)
+
+// NewRenderValidator returns the render validation of the webhook , rendering the chart with the explicit
+// capabilities of the application and converting it to federated objects served by the host cluster ,
+// reading referenced HelmRepositories with the reader , the validation stops between the steps once the
+// context is done
+func NewRenderValidator(config *rest.Config, reader client.Reader) func(ctx context.Context, application *federationv1.Application) error {
+	return func(ctx context.Context, application *federationv1.Application) error {
+		repository, err := federationv1.ResolveChartRepository(reader, application.Namespace, application.Spec.Template.Chart)
+		if err != nil {
+			return err
+		}
+		inputs, err := revisionInputs(*application.WithChartRepository(repository))
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		helmClient, err := util.NewHelmClient(config)
+		if err != nil {
+			return err
+		}
+		capabilities := util.ExplicitCapabilities(inputs.KubeVersion, inputs.APIVersions)
+		rendered, err := helmClient.Render(inputs.ReleaseName, inputs.Chart, inputs.Repo, util.GlobalOptions{
+			Namespace:    inputs.Namespace,
+			Version:      inputs.Version,
+			Values:       inputs.Values,
+			Capabilities: capabilities,
+			Credentials:  repositoryCredentials(repository),
+		})
+		if valuesErr, ok := err.(*util.ValuesError); ok {
+			return apierrors.NewInvalid(federationv1.GroupVersion.WithKind("Application").GroupKind(), application.Name, valuesFieldErrors(valuesErr))
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to render chart %s: %v", inputs.Chart, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		converter, err := util.NewFederatedResourceConverter(&rendered.Manifest)
+		if err != nil {
+			return err
+		}
+		fedResources, err := converter.GenerateFederatedUnstructuredList(&rendered.Manifest)
+		if err != nil {
+			return fmt.Errorf("Unable to federate chart %s: %v", inputs.Chart, err)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		getter, err := util.NewRESTClientGetter(config)
+		if err != nil {
+			return err
+		}
+		return getter.CheckServed(fedResources)
+	}
+}
+
+// valuesPath is the field path of the chart values in the application
+var valuesPath = field.NewPath("spec", "template", "chart", "values")
+
+// valuesFieldErrors translates the schema violations of the chart values to field errors of the application
+func valuesFieldErrors(valuesErr *util.ValuesError) field.ErrorList {
+	var errs field.ErrorList
+	for _, each := range valuesErr.Errors {
+		path := valuesPath
+		if each.Path != "" {
+			for _, segment := range strings.Split(each.Path, ".") {
+				if index, err := strconv.Atoi(segment); err == nil {
+					path = path.Index(index)
+				} else {
+					path = path.Child(segment)
+				}
+			}
+		}
+		errs = append(errs, field.Invalid(path, each.Value, each.Message))
+	}
+	return errs
+}
+
+// setValuesCondition records whether the chart values match the values schema of the chart
+func setValuesCondition(application *federationv1.Application, valuesErr *util.ValuesError) {
+	if valuesErr == nil {
+		application.Status.SetCondition(federationv1.ApplicationCondition{
+			Type:   federationv1.ValuesValid,
+			Status: corev1.ConditionTrue,
+			Reason: "SchemaValidated",
+		})
+		return
+	}
+	application.Status.SetCondition(federationv1.ApplicationCondition{
+		Type:    federationv1.ValuesValid,
+		Status:  corev1.ConditionFalse,
+		Reason:  "SchemaViolation",
+		Message: valuesFieldErrors(valuesErr).ToAggregate().Error(),
+	})
+}
@@ -3,11 +3,15 @@ module kubefed-application-controller
 go 1.13
 
 require (
+	github.com/Masterminds/semver/v3 v3.0.3
 	github.com/go-logr/logr v0.1.0
 	github.com/onsi/ginkgo v1.14.0
 	github.com/onsi/gomega v1.10.1
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/xeipuuv/gojsonschema v1.1.0
 	gonum.org/v1/netlib v0.0.0-20190331212654-76723241ea4e // indirect
 	helm.sh/helm/v3 v3.1.3
+	k8s.io/api v0.17.3
 	k8s.io/apimachinery v0.17.3
 	k8s.io/cli-runtime v0.17.3
 	k8s.io/client-go v0.17.3
@@ -15,5 +19,4 @@ require (
 	sigs.k8s.io/kubefed v0.3.0
 	sigs.k8s.io/structured-merge-diff v1.0.1-0.20191108220359-b1b620dd3f06 // indirect
 	sigs.k8s.io/yaml v1.2.0
-
 )
@@ -17,17 +17,25 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	federationv1 "kubefed-application-controller/api/v1"
+	federationv2 "kubefed-application-controller/api/v2"
 	"kubefed-application-controller/controllers"
+	"kubefed-application-controller/controllers/util"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -40,16 +48,56 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
 	_ = federationv1.AddToScheme(scheme)
+	_ = federationv2.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var kubefedNamespace string
+	var suspendSelector string
+	var federatedKinds string
+	var requireServiceAccount bool
+	var allowedRepos string
+	var deniedRepos string
+	var repoPolicyConfigMap string
+	var resolveCharts bool
+	var resolveTimeout time.Duration
+	var validateRender bool
+	var renderTimeout time.Duration
+	var clusterApplicationNamespace string
+	var capabilitiesTTL time.Duration
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&kubefedNamespace, "kubefed-namespace", "kube-federation-system",
+		"The namespace of the kubefed control plane holding the KubeFedCluster objects of the member clusters.")
+	flag.StringVar(&suspendSelector, "suspend-selector", "",
+		"Label selector of Applications to suspend. Matching Applications are not rendered or applied until removed from the selector.")
+	flag.StringVar(&federatedKinds, "federated-kinds", "FederatedDeployment,FederatedService,FederatedConfigMap",
+		"Comma separated kinds of the types.kubefed.io federated types to watch for drift of the generated objects.")
+	flag.BoolVar(&requireServiceAccount, "require-service-account", false,
+		"Reject Applications without spec.serviceAccountName , so federated objects are never applied with the controller's own permissions.")
+	flag.StringVar(&allowedRepos, "allowed-repos", "",
+		"Comma separated chart repository url patterns Applications may use , * matches any characters. All repositories are allowed when empty.")
+	flag.StringVar(&deniedRepos, "denied-repos", "",
+		"Comma separated chart repository url patterns Applications may not use , taking precedence over the allowed repositories.")
+	flag.StringVar(&repoPolicyConfigMap, "repo-policy-configmap", "",
+		"Namespace/name of a ConfigMap with additional repository url patterns in its allowed and denied keys , read on every admission.")
+	flag.BoolVar(&resolveCharts, "webhook-resolve-charts", false,
+		"Reject Applications whose chart version cannot be fetched from the repository.")
+	flag.DurationVar(&resolveTimeout, "webhook-resolve-timeout", 10*time.Second,
+		"Time budget of resolving a chart in the validating webhook.")
+	flag.BoolVar(&validateRender, "webhook-validate-render", false,
+		"Reject Applications whose chart fails to render or to convert to federated types served by the host cluster.")
+	flag.DurationVar(&renderTimeout, "webhook-render-timeout", 5*time.Second,
+		"Time budget of rendering a chart in the validating webhook.")
+	flag.StringVar(&clusterApplicationNamespace, "cluster-application-namespace", "",
+		"The namespace holding the revisions and impersonated service accounts of ClusterApplications , defaults to the kubefed namespace.")
+	flag.DurationVar(&capabilitiesTTL, "capabilities-cache-ttl", 5*time.Minute,
+		"How long the discovered Kubernetes version and API versions of a member cluster are used before discovering it again.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
@@ -66,20 +114,104 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controllers.ApplicationReconciler{
-		Client: mgr.GetClient(),
-		Config: mgr.GetConfig(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Application"),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	suspended, err := labels.Parse(suspendSelector)
+	if err != nil {
+		setupLog.Error(err, "invalid suspend selector")
+		os.Exit(1)
+	}
+
+	memberClusters, err := util.NewKubeFedMemberClusters(mgr.GetConfig(), kubefedNamespace)
+	if err != nil {
+		setupLog.Error(err, "unable to create member cluster client")
+		os.Exit(1)
+	}
+
+	if clusterApplicationNamespace == "" {
+		clusterApplicationNamespace = kubefedNamespace
+	}
+	applicationReconciler := &controllers.ApplicationReconciler{
+		Client:                      mgr.GetClient(),
+		Config:                      mgr.GetConfig(),
+		Log:                         ctrl.Log.WithName("controllers").WithName("Application"),
+		Scheme:                      mgr.GetScheme(),
+		HealthChecker:               util.NewWorkloadHealthChecker(memberClusters),
+		Revisions:                   util.NewSecretRevisionStore(mgr.GetClient(), mgr.GetAPIReader()),
+		MemberClusters:              memberClusters,
+		Clusters:                    memberClusters,
+		ClusterCapabilities:         util.NewCapabilitiesCache(memberClusters, capabilitiesTTL),
+		SuspendSelector:             suspended,
+		FederatedKinds:              federatedGroupVersionKinds(federatedKinds),
+		RequireServiceAccount:       requireServiceAccount,
+		ClusterApplicationNamespace: clusterApplicationNamespace,
+	}
+	if err = applicationReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Application")
 		os.Exit(1)
 	}
+	if err = (&controllers.ClusterApplicationReconciler{
+		ApplicationReconciler: applicationReconciler,
+		Log:                   ctrl.Log.WithName("controllers").WithName("ClusterApplication"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterApplication")
+		os.Exit(1)
+	}
+	if err = (&controllers.ApplicationSetReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("ApplicationSet"),
+		Scheme:   mgr.GetScheme(),
+		Clusters: memberClusters,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ApplicationSet")
+		os.Exit(1)
+	}
+	repositories := federationv1.RepositoryPolicy{
+		Allowed: federationv1.ParseRepositoryPatterns(allowedRepos),
+		Denied:  federationv1.ParseRepositoryPatterns(deniedRepos),
+	}
+	var repositoryPolicyConfigMap types.NamespacedName
+	if repoPolicyConfigMap != "" {
+		parts := strings.SplitN(repoPolicyConfigMap, "/", 2)
+		if len(parts) != 2 {
+			setupLog.Error(nil, "invalid repository policy ConfigMap , expected namespace/name", "configmap", repoPolicyConfigMap)
+			os.Exit(1)
+		}
+		repositoryPolicyConfigMap = types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	}
+	if err = (&controllers.HelmRepositoryReconciler{
+		Client:                    mgr.GetClient(),
+		Log:                       ctrl.Log.WithName("controllers").WithName("HelmRepository"),
+		Scheme:                    mgr.GetScheme(),
+		Repositories:              repositories,
+		RepositoryPolicyConfigMap: repositoryPolicyConfigMap,
+		APIReader:                 mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HelmRepository")
+		os.Exit(1)
+	}
 	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
-		if err = (&federationv1.Application{}).SetupWebhookWithManager(mgr); err != nil {
+		webhookOptions := federationv1.WebhookOptions{
+			Repositories:              repositories,
+			RepositoryPolicyConfigMap: repositoryPolicyConfigMap,
+			ResolveTimeout:            resolveTimeout,
+			RenderTimeout:             renderTimeout,
+		}
+		if resolveCharts {
+			helmClient, _ := util.NewHelmClient(mgr.GetConfig())
+			webhookOptions.ResolveChart = func(ctx context.Context, chartName string, chartRepo string, version string, username string, password string) (string, error) {
+				return helmClient.ResolveChart(ctx, chartName, chartRepo, version, util.RepositoryCredentials{Username: username, Password: password})
+			}
+		}
+		if validateRender {
+			webhookOptions.ValidateRender = controllers.NewRenderValidator(mgr.GetConfig(), mgr.GetAPIReader())
+		}
+		if err = (&federationv1.Application{}).SetupWebhookWithManager(mgr, webhookOptions); err != nil {
 			setupLog.Error(err, "unable to create webhook", "webhook", "Application")
 			os.Exit(1)
 		}
+		if err = (&federationv1.ClusterApplication{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ClusterApplication")
+			os.Exit(1)
+		}
 	}
 	// +kubebuilder:scaffold:builder
 
@@ -89,3 +221,16 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// federatedGroupVersionKinds maps the comma separated federated kinds to their kubefed group version
+func federatedGroupVersionKinds(kinds string) []schema.GroupVersionKind {
+	var result []schema.GroupVersionKind
+	for _, kind := range strings.Split(kinds, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		result = append(result, schema.GroupVersionKind{Group: "types.kubefed.io", Version: "v1beta1", Kind: kind})
+	}
+	return result
+}